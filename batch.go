@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"go-fee-proxy-reference/feeproxy"
+)
+
+// batchRow is one transfer of a batch, and its outcome.
+type batchRow struct {
+	line      int
+	recipient common.Address
+	rawAmount string
+	amount    *big.Int
+	token     common.Address
+
+	tx       *types.Transaction
+	status   string
+	gasUsed  uint64
+	feeXRP   *big.Int
+	feeAsset *big.Int
+	err      error
+}
+
+func cmdBatch(args []string) error {
+	var o options
+	fs := newFlagSet("batch", &o)
+	inputFlag := fs.String("input", "", "CSV file of recipient,amount[,token] rows, amounts in the token's units")
+	outputFlag := fs.String("output", "", "CSV file to write the results to (defaults to stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	rows, err := readBatch(&o, *inputFlag)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+
+	s, err := o.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	// the output file is only created once the batch can be sent, so a
+	// failure to connect does not leave an empty report behind
+	out := os.Stdout
+	if *outputFlag != "" {
+		out, err = os.Create(*outputFlag)
+		if err != nil {
+			return fmt.Errorf("could not create output file: %v", err)
+		}
+		defer out.Close()
+	}
+
+	sendBatch(s, rows, o.timeout)
+
+	// fees are written in units of the fee asset when its metadata can be read
+	infoCtx, infoCancel := context.WithTimeout(context.Background(), o.timeout)
+	defer infoCancel()
+	feeAsset, _ := s.tokens.Info(infoCtx, s.asset)
+	if err := writeBatch(out, rows, feeAsset); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, row := range rows {
+		if row.err != nil {
+			failed++
+		}
+	}
+	if failed != 0 {
+		return fmt.Errorf("%v of %v transfers failed", failed, len(rows))
+	}
+	log.Printf("All %v transfers succeeded", len(rows))
+	return nil
+}
+
+// readBatch reads the rows of a batch file. A header row and lines starting
+// with # are skipped. Rows that cannot be parsed are kept with their error so
+// they are reported with the rest.
+func readBatch(o *options, path string) ([]*batchRow, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no input file given")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open input file: %v", err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+
+	var rows []*batchRow
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read input file: %v", err)
+		}
+		line, _ := r.FieldPos(0)
+		if len(rows) == 0 && strings.EqualFold(record[0], "recipient") {
+			continue
+		}
+		rows = append(rows, parseBatchRow(o, line, record))
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("input file %v has no rows", path)
+	}
+	return rows, nil
+}
+
+func parseBatchRow(o *options, line int, record []string) *batchRow {
+	row := &batchRow{line: line, status: "invalid"}
+	if len(record) < 2 || len(record) > 3 {
+		row.err = fmt.Errorf("line %v: expected recipient,amount[,token], got %v fields", line, len(record))
+		return row
+	}
+
+	var err error
+	if row.recipient, err = parseAddress("recipient", record[0]); err != nil {
+		row.err = fmt.Errorf("line %v: %v", line, err)
+		return row
+	}
+	// amounts are parsed once the token's decimals can be read from the chain
+	row.rawAmount = record[1]
+	if len(record) == 3 && record[2] != "" {
+		row.token, err = o.token("token", record[2])
+	} else {
+		row.token, err = o.feeAsset()
+	}
+	if err != nil {
+		row.err = fmt.Errorf("line %v: %v", line, err)
+	}
+	return row
+}
+
+// sendBatch sends a transfer for every valid row, taking consecutive nonces
+// from the client's nonce manager, then waits for all of them. Sending and
+// confirming each row has its own timeout, so a long batch does not run out
+// of time on its later rows. A row that fails does not stop the others.
+func sendBatch(s *session, rows []*batchRow, timeout time.Duration) {
+	for _, row := range rows {
+		if row.err != nil {
+			continue
+		}
+		sendRow(s, row, timeout)
+	}
+	for _, row := range rows {
+		if row.tx == nil {
+			continue
+		}
+		confirmRow(s, row, timeout)
+	}
+}
+
+// sendRow sends the transfer of row.
+func sendRow(s *session, row *batchRow, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	amount, err := s.parseAmount(ctx, "amount", row.token, row.rawAmount)
+	if err != nil {
+		row.err = fmt.Errorf("line %v: %v", row.line, err)
+		return
+	}
+	row.amount = amount
+
+	input, err := feeproxy.PackTxData(feeproxy.SyloTokenMetaData, "transfer", row.recipient, row.amount)
+	if err != nil {
+		row.status, row.err = "error", err
+		return
+	}
+
+	log.Printf("Transferring %v (%v) to %v", s.format(ctx, row.token, row.amount), row.token.Hex(), row.recipient.Hex())
+
+	row.tx, err = s.submit(ctx, row.token, input)
+	if err != nil {
+		row.status, row.err = "error", err
+		return
+	}
+	row.status = "pending"
+}
+
+// confirmRow waits for the receipt of the transfer sent for row and records
+// its outcome and fees.
+func confirmRow(s *session, row *batchRow, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	receipt, err := s.confirm(ctx, row.tx)
+	if receipt == nil {
+		row.err = err
+		return
+	}
+	row.gasUsed = receipt.GasUsed
+	row.feeXRP = new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), receipt.GasPrice(row.tx))
+	if sent, sentErr := feeproxy.TokensSent(receipt.Receipt, s.asset, s.opts.From); sentErr == nil {
+		if row.token == s.asset && receipt.Succeeded() {
+			sent.Sub(sent, row.amount)
+		}
+		row.feeAsset = sent
+	}
+	if err != nil {
+		row.status, row.err = "failed", err
+		return
+	}
+	row.status = "success"
+}
+
+// writeBatch writes the outcome of every row as CSV. Amounts are written as
+// given, XRP fees in XRP and fee asset fees in units of feeAsset, or in base
+// units if it is nil.
+func writeBatch(out io.Writer, rows []*batchRow, feeAsset *feeproxy.TokenInfo) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"line", "recipient", "amount", "token", "tx_hash", "status", "gas_used", "fee_xrp", "fee_asset", "error"})
+	for _, row := range rows {
+		record := []string{strconv.Itoa(row.line), "", "", "", "", row.status, "", "", "", ""}
+		if row.recipient != (common.Address{}) {
+			record[1] = row.recipient.Hex()
+		}
+		record[2] = row.rawAmount
+		if row.token != (common.Address{}) {
+			record[3] = row.token.Hex()
+		}
+		if row.tx != nil {
+			record[4] = row.tx.Hash().Hex()
+		}
+		if row.gasUsed != 0 {
+			record[6] = strconv.FormatUint(row.gasUsed, 10)
+		}
+		if row.feeXRP != nil {
+			record[7] = feeproxy.FormatUnits(row.feeXRP, feeproxy.NativeXRP.Decimals)
+		}
+		switch {
+		case row.feeAsset != nil && feeAsset != nil:
+			record[8] = feeproxy.FormatUnits(row.feeAsset, feeAsset.Decimals)
+		case row.feeAsset != nil:
+			record[8] = row.feeAsset.String()
+		}
+		if row.err != nil {
+			record[9] = row.err.Error()
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("could not write results: %v", err)
+	}
+	return nil
+}
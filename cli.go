@@ -0,0 +1,535 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"go-fee-proxy-reference/feeproxy"
+)
+
+const (
+	defaultNetwork = "porcini"
+	// environment variable holding the sender's key when no other source is given
+	defaultKeyEnv = "FEE_PROXY_KEY"
+)
+
+// options are the flags shared by every command.
+type options struct {
+	network     string
+	networks    string
+	tokensFile  string
+	rpcURL      string
+	feeProxy    string
+	asset       string
+	assetID     string
+	keys        keyOptions
+	maxPayment  string
+	dex         string
+	slippage    uint64
+	gasLimit    uint64
+	overhead    uint64
+	multiplier  uint64
+	confirms    uint64
+	feePolicy   string
+	legacy      bool
+	timeout     time.Duration
+	noPreflight bool
+	// from is the sender when building a transaction to sign offline, in
+	// which case no key is needed
+	from string
+
+	profile *feeproxy.Network
+	tokens  []*feeproxy.TokenConfig
+}
+
+func newFlagSet(name string, o *options) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&o.network, "network", defaultNetwork, "network profile to use (porcini, root or local, or one from --networks-file)")
+	fs.StringVar(&o.networks, "networks-file", "", "JSON file of additional network profiles")
+	fs.StringVar(&o.tokensFile, "tokens-file", "", "JSON file of tokens known by symbol, in addition to the network's")
+	fs.StringVar(&o.rpcURL, "rpc", "", "RPC URL of the node (defaults to the network's)")
+	fs.StringVar(&o.feeProxy, "fee-proxy", "", "address of the fee proxy precompile (defaults to the network's)")
+	fs.StringVar(&o.asset, "asset", "", "address or symbol of the asset the fee is paid in (defaults to the network's)")
+	fs.StringVar(&o.assetID, "asset-id", "", "Root Network asset id of the asset the fee is paid in, instead of --asset")
+	o.keys.register(fs)
+	fs.StringVar(&o.maxPayment, "max-payment", "", "maximum amount of the fee asset to pay, such as 1.5 or 1.5 SYLO (quoted from the dex if empty)")
+	fs.StringVar(&o.dex, "dex", "", "address of the dex precompile used to quote the max payment (defaults to the network's)")
+	fs.Uint64Var(&o.slippage, "slippage-bps", feeproxy.DefaultSlippageBps, "buffer added to the dex quote, in basis points")
+	fs.Uint64Var(&o.gasLimit, "gas-limit", 0, "gas limit of the transaction (estimated if zero)")
+	fs.Uint64Var(&o.overhead, "gas-overhead", feeproxy.DefaultGasOverhead, "gas added to the inner call estimate for the fee proxy")
+	fs.Uint64Var(&o.multiplier, "gas-multiplier", feeproxy.DefaultGasMultiplier, "percentage the gas estimate is scaled by")
+	fs.StringVar(&o.feePolicy, "fee-policy", string(feeproxy.FeeNormal), "how aggressively to price gas: economy, normal or urgent")
+	fs.BoolVar(&o.legacy, "legacy", false, "send legacy transactions with a gas price instead of EIP-1559 fee caps")
+	fs.Uint64Var(&o.confirms, "confirmations", 1, "number of blocks to wait for after the transaction is mined")
+	fs.BoolVar(&o.noPreflight, "skip-preflight", false, "send without checking balances and simulating the call first")
+	fs.DurationVar(&o.timeout, "timeout", time.Second*60, "time to wait for the command to complete")
+	return fs
+}
+
+// session holds everything a command needs to talk to the chain.
+type session struct {
+	evmClient  *ethclient.Client
+	chainID    *big.Int
+	opts       *bind.TransactOpts
+	client     *feeproxy.Client
+	quoter     *feeproxy.Quoter
+	estimator  *feeproxy.GasEstimator
+	waiter     *feeproxy.Waiter
+	fees       *feeproxy.FeeStrategy
+	tokens     *feeproxy.TokenRegistry
+	asset      common.Address
+	maxPayment *big.Int
+	gasLimit   uint64
+	preflight  bool
+}
+
+// loadNetwork returns the network profile picked by the flags.
+func (o *options) loadNetwork() (*feeproxy.Network, error) {
+	if o.profile != nil {
+		return o.profile, nil
+	}
+	networks := feeproxy.Networks
+	if o.networks != "" {
+		loaded, err := feeproxy.LoadNetworks(o.networks)
+		if err != nil {
+			return nil, err
+		}
+		networks = loaded
+	}
+	network, ok := networks[o.network]
+	if !ok {
+		return nil, fmt.Errorf("unknown network %q, expected one of %v", o.network, strings.Join(feeproxy.NetworkNames(networks), ", "))
+	}
+	o.profile = network
+	return network, nil
+}
+
+// loadTokens returns the tokens of the tokens file, if one was given.
+func (o *options) loadTokens() ([]*feeproxy.TokenConfig, error) {
+	if o.tokens != nil || o.tokensFile == "" {
+		return o.tokens, nil
+	}
+	tokens, err := feeproxy.LoadTokens(o.tokensFile)
+	if err != nil {
+		return nil, err
+	}
+	o.tokens = tokens
+	return tokens, nil
+}
+
+// token parses a token given by address or by its symbol in the tokens file
+// or the network profile.
+func (o *options) token(name, s string) (common.Address, error) {
+	if common.IsHexAddress(s) {
+		return common.HexToAddress(s), nil
+	}
+	tokens, err := o.loadTokens()
+	if err != nil {
+		return common.Address{}, err
+	}
+	for _, token := range tokens {
+		if strings.EqualFold(token.Symbol, s) {
+			return token.TokenAddress()
+		}
+	}
+	network, err := o.loadNetwork()
+	if err != nil {
+		return common.Address{}, err
+	}
+	if address, ok := network.Token(s); ok {
+		return address, nil
+	}
+	return common.Address{}, fmt.Errorf("invalid %s: %q is not an address or a token known on %v", name, s, network.Name)
+}
+
+// feeAsset returns the asset the fee is paid in.
+func (o *options) feeAsset() (common.Address, error) {
+	if o.assetID != "" {
+		if o.asset != "" {
+			return common.Address{}, fmt.Errorf("--asset and --asset-id cannot both be given")
+		}
+		return parseAssetID("asset-id", o.assetID)
+	}
+	if o.asset != "" {
+		return o.token("asset", o.asset)
+	}
+	network, err := o.loadNetwork()
+	if err != nil {
+		return common.Address{}, err
+	}
+	return o.token("asset", network.FeeAsset)
+}
+
+// tokenRegistry returns a registry of the tokens of the network profile and
+// the tokens file, reading their metadata with caller.
+func (o *options) tokenRegistry(caller bind.ContractCaller) (*feeproxy.TokenRegistry, error) {
+	network, err := o.loadNetwork()
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := o.loadTokens()
+	if err != nil {
+		return nil, err
+	}
+
+	registry := feeproxy.NewTokenRegistry(caller)
+	for symbol, address := range network.Tokens {
+		address := address
+		if err := registry.Register(&feeproxy.TokenConfig{Symbol: symbol, Address: &address}); err != nil {
+			return nil, err
+		}
+	}
+	for _, token := range tokens {
+		if err := registry.Register(token); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// dial connects to the node of the network profile, checking that it is on
+// the expected chain.
+func (o *options) dial(ctx context.Context) (*ethclient.Client, *big.Int, error) {
+	network, err := o.loadNetwork()
+	if err != nil {
+		return nil, nil, err
+	}
+	rpcURL := network.RPC
+	if o.rpcURL != "" {
+		rpcURL = o.rpcURL
+	}
+
+	evmClient, err := ethclient.Dial(rpcURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create ethereum virtual client: %v", err)
+	}
+
+	chainID, err := network.CheckChainID(ctx, evmClient)
+	if err != nil {
+		return nil, nil, err
+	}
+	return evmClient, chainID, nil
+}
+
+func (o *options) connect(ctx context.Context) (*session, error) {
+	network, err := o.loadNetwork()
+	if err != nil {
+		return nil, err
+	}
+	feeProxyAddress := network.FeeProxy
+	if o.feeProxy != "" {
+		if feeProxyAddress, err = parseAddress("fee-proxy", o.feeProxy); err != nil {
+			return nil, err
+		}
+	}
+	dexAddress := network.Dex
+	if o.dex != "" {
+		if dexAddress, err = parseAddress("dex", o.dex); err != nil {
+			return nil, err
+		}
+	}
+	asset, err := o.feeAsset()
+	if err != nil {
+		return nil, err
+	}
+	feePolicy, err := feeproxy.ParseFeePolicy(o.feePolicy)
+	if err != nil {
+		return nil, err
+	}
+	var keySource feeproxy.KeySource
+	var from common.Address
+	if o.from != "" {
+		if from, err = parseAddress("from", o.from); err != nil {
+			return nil, err
+		}
+	} else if keySource, err = o.keys.source(); err != nil {
+		return nil, err
+	}
+
+	evmClient, chainID, err := o.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// without a key the session can build transactions but not sign them
+	opts := &bind.TransactOpts{From: from}
+	if keySource != nil {
+		if opts, err = keySource.Transactor(chainID); err != nil {
+			return nil, err
+		}
+	}
+
+	client, err := feeproxy.NewClient(feeProxyAddress, evmClient, opts)
+	if err != nil {
+		return nil, err
+	}
+	client.SetNonceManager(feeproxy.NewNonceManager(evmClient, opts.From))
+
+	quoter, err := feeproxy.NewQuoter(dexAddress, evmClient, o.slippage)
+	if err != nil {
+		return nil, err
+	}
+
+	estimator := feeproxy.NewGasEstimator(client)
+	estimator.Overhead = o.overhead
+	estimator.Multiplier = o.multiplier
+
+	waiter := feeproxy.NewWaiter(evmClient)
+	waiter.Confirmations = o.confirms
+
+	fees := feeproxy.NewFeeStrategy(evmClient, feePolicy)
+	fees.Legacy = o.legacy
+
+	tokens, err := o.tokenRegistry(evmClient)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &session{
+		evmClient: evmClient,
+		chainID:   chainID,
+		opts:      opts,
+		client:    client,
+		quoter:    quoter,
+		estimator: estimator,
+		waiter:    waiter,
+		fees:      fees,
+		tokens:    tokens,
+		asset:     asset,
+		gasLimit:  o.gasLimit,
+		preflight: !o.noPreflight,
+	}
+	if o.maxPayment != "" {
+		if s.maxPayment, err = s.parseAmount(ctx, "max-payment", asset, o.maxPayment); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// parseAmount parses an amount of token given in the token's units, such as
+// "12.5" or "12.5 SYLO".
+func (s *session) parseAmount(ctx context.Context, name string, token common.Address, amount string) (*big.Int, error) {
+	info, err := s.tokens.Info(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := info.ParseAmount(amount)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", name, err)
+	}
+	return parsed, nil
+}
+
+// format formats an amount of token in the token's units, falling back to
+// base units if the token's metadata cannot be read.
+func (s *session) format(ctx context.Context, token common.Address, amount *big.Int) string {
+	return formatAmount(ctx, s.tokens, token, amount)
+}
+
+func formatAmount(ctx context.Context, tokens *feeproxy.TokenRegistry, token common.Address, amount *big.Int) string {
+	info, err := tokens.Info(ctx, token)
+	if err != nil {
+		return fmt.Sprintf("%v wei of %v", amount, token.Hex())
+	}
+	return info.FormatAmount(amount)
+}
+
+// quoteMaxPayment returns the max payment given on the command line, or
+// quotes one from the dex for gasLimit. Either way the fees are pinned on the
+// transact opts, so the fee cannot exceed a quote and the fee flags apply.
+func (s *session) quoteMaxPayment(ctx context.Context, gasLimit uint64) (*big.Int, error) {
+	gasPrice, err := s.gasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s.maxPayment != nil {
+		return s.maxPayment, nil
+	}
+	maxPayment, err := s.quoter.MaxPayment(ctx, s.asset, gasLimit, gasPrice)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Quoted max payment of %v for gas limit=%v, max gas price=%v", s.format(ctx, s.asset, maxPayment), gasLimit, gasPrice)
+	return maxPayment, nil
+}
+
+// gasPrice returns the most a transaction may pay per gas, pinning fees from
+// the fee strategy on the transact opts if none are set.
+func (s *session) gasPrice(ctx context.Context) (*big.Int, error) {
+	if s.opts.GasPrice == nil && s.opts.GasFeeCap == nil {
+		fees, err := s.fees.Fees(ctx)
+		if err != nil {
+			return nil, err
+		}
+		fees.Apply(s.opts)
+		log.Printf("Using %v fees: %v", s.fees.Policy, fees)
+	}
+	if s.opts.GasPrice != nil {
+		return s.opts.GasPrice, nil
+	}
+	return s.opts.GasFeeCap, nil
+}
+
+// prepare sets the gas limit for calling target with input, estimating it if
+// it was not given, and returns the max payment for that gas limit.
+func (s *session) prepare(ctx context.Context, target common.Address, input []byte) (*big.Int, error) {
+	if s.gasLimit != 0 {
+		s.opts.GasLimit = s.gasLimit
+		return s.quoteMaxPayment(ctx, s.opts.GasLimit)
+	}
+
+	estimate, maxPayment, err := s.estimator.Prepare(ctx, s.asset, target, input, s.quoteMaxPayment)
+	if err != nil {
+		return nil, err
+	}
+	if estimate.Err != nil {
+		log.Printf("Gas estimation failed, using fallback gas limit: %v", estimate.Err)
+	}
+	log.Printf("Estimated gas limit for fee proxy transaction %v (%v)", estimate.GasLimit, estimate.Method)
+
+	s.opts.GasLimit = estimate.GasLimit
+	return maxPayment, nil
+}
+
+// maxRetries is the number of times a rejected transaction is resent with a
+// higher gas limit or max payment.
+const maxRetries = 2
+
+// send submits a fee proxy transaction and waits for its receipt.
+func (s *session) send(ctx context.Context, target common.Address, input []byte) error {
+	tx, err := s.submit(ctx, target, input)
+	if err != nil {
+		return err
+	}
+	_, err = s.confirm(ctx, tx)
+	return err
+}
+
+// submit sends a fee proxy transaction, retrying with a higher gas limit or
+// max payment if it is rejected for being too low.
+func (s *session) submit(ctx context.Context, target common.Address, input []byte) (*types.Transaction, error) {
+	maxPayment, err := s.prepare(ctx, target, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.preflight {
+		if err := s.client.Preflight(ctx, s.asset, maxPayment, target, input); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Printf("Sending Fee Proxy Transaction for token=%v, target=%v", s.asset.Hex(), target.Hex())
+
+	tx, err := s.client.Send(ctx, s.asset, maxPayment, target, input)
+	for retry := 0; err != nil && retry < maxRetries; retry++ {
+		switch {
+		case errors.Is(err, feeproxy.ErrGasLimitTooLow):
+			s.opts.GasLimit = s.opts.GasLimit * 3 / 2
+			log.Printf("Gas limit too low, retrying with gas limit=%v", s.opts.GasLimit)
+			if maxPayment, err = s.quoteMaxPayment(ctx, s.opts.GasLimit); err != nil {
+				return nil, err
+			}
+		case errors.Is(err, feeproxy.ErrMaxPaymentTooLow) && s.maxPayment == nil:
+			// only quoted payments are raised, never the cap given with
+			// --max-payment
+			maxPayment = feeproxy.ApplySlippage(maxPayment, 5000)
+			log.Printf("Max payment too low, retrying with max payment=%v", s.format(ctx, s.asset, maxPayment))
+		default:
+			return nil, err
+		}
+		tx, err = s.client.Send(ctx, s.asset, maxPayment, target, input)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Sent Fee Proxy transaction: %v, nonce=%v", tx.Hash(), tx.Nonce())
+
+	return tx, nil
+}
+
+// confirm waits for tx to be mined and confirmed, returning an error if it
+// failed. Replacements of tx with the same nonce may be given, in which
+// case whichever of them is mined is confirmed.
+func (s *session) confirm(ctx context.Context, tx *types.Transaction, replacements ...*types.Transaction) (*feeproxy.Receipt, error) {
+	log.Printf("Waiting for tx receipt with %v confirmations...", s.waiter.Confirmations)
+
+	txs := append([]*types.Transaction{tx}, replacements...)
+	hashes := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash()
+	}
+	receipt, err := s.waiter.WaitAny(ctx, hashes...)
+	if err != nil {
+		return nil, err
+	}
+	for _, candidate := range txs {
+		if candidate.Hash() == receipt.TxHash {
+			tx = candidate
+		}
+	}
+	if len(replacements) > 0 {
+		log.Printf("Mined transaction: %v", tx.Hash())
+	}
+	if receipt.Reorgs > 0 {
+		log.Printf("Transaction was reorged %v times before confirming", receipt.Reorgs)
+	}
+	if err := s.client.CheckReceipt(ctx, tx, receipt); err != nil {
+		return receipt, err
+	}
+
+	log.Printf("Successfully received tx receipt. Status=%v, Block=%v, Gas used=%v", receipt.Status, receipt.BlockNumber, receipt.GasUsed)
+
+	return receipt, nil
+}
+
+func parseAddress(name, s string) (common.Address, error) {
+	if !common.IsHexAddress(s) {
+		return common.Address{}, fmt.Errorf("invalid %s address: %q", name, s)
+	}
+	return common.HexToAddress(s), nil
+}
+
+func parseHash(name, s string) (common.Hash, error) {
+	b, err := hexutil.Decode(s)
+	if err != nil || len(b) != common.HashLength {
+		return common.Hash{}, fmt.Errorf("invalid %s hash: %q", name, s)
+	}
+	return common.BytesToHash(b), nil
+}
+
+// parseAssetID parses a Root Network asset id into the address of its ERC-20
+// precompile.
+func parseAssetID(name, s string) (common.Address, error) {
+	id, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("invalid %s: %q is not an asset id", name, s)
+	}
+	return feeproxy.AssetAddress(uint32(id)), nil
+}
+
+func parseHex(name, s string) ([]byte, error) {
+	if !strings.HasPrefix(s, "0x") {
+		s = "0x" + s
+	}
+	b, err := hexutil.Decode(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s data: %v", name, err)
+	}
+	return b, nil
+}
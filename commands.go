@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"go-fee-proxy-reference/feeproxy"
+)
+
+func cmdBalance(args []string) error {
+	var o options
+	fs := newFlagSet("balance", &o)
+	account := fs.String("account", "", "account to show balances of (defaults to the sender)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *account != "" {
+		owner, err := parseAddress("account", *account)
+		if err != nil {
+			return err
+		}
+		// balances are only read, so no key is needed for another account
+		o.from = owner.Hex()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+
+	s, err := o.connect(ctx)
+	if err != nil {
+		return err
+	}
+	owner := s.opts.From
+
+	token, err := feeproxy.NewSyloToken(s.asset, s.evmClient)
+	if err != nil {
+		return fmt.Errorf("failed to bind token contract: %v", err)
+	}
+
+	tokenBalance, err := token.BalanceOf(nil, owner)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve token balance: %v", err)
+	}
+
+	xrpBalance, err := s.evmClient.BalanceAt(ctx, owner, nil)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve xrp balance: %v", err)
+	}
+
+	log.Printf("Account: %v", owner.Hex())
+	log.Printf("Account XRP Balance: %v", feeproxy.NativeXRP.FormatAmount(xrpBalance))
+	log.Printf("Account Token Balance: %v (%v)", s.format(ctx, s.asset, tokenBalance), s.asset.Hex())
+
+	return nil
+}
+
+func cmdToken(args []string) error {
+	var o options
+	fs := newFlagSet("token", &o)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected the address, symbol or asset id of one token")
+	}
+	token := fs.Arg(0)
+	if _, err := strconv.ParseUint(token, 10, 32); err == nil {
+		address, err := parseAssetID("token", token)
+		if err != nil {
+			return err
+		}
+		token = address.Hex()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+
+	evmClient, _, err := o.dial(ctx)
+	if err != nil {
+		return err
+	}
+	registry, err := o.tokenRegistry(evmClient)
+	if err != nil {
+		return err
+	}
+	info, err := registry.Lookup(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Token: %v (%v)", info.Name, info.Symbol)
+	log.Printf("Address: %v", info.Address.Hex())
+	if info.AssetID != 0 {
+		log.Printf("Asset ID: %v", info.AssetID)
+	}
+	log.Printf("Decimals: %v", info.Decimals)
+	if info.TotalSupply != nil {
+		log.Printf("Total Supply: %v", info.FormatAmount(info.TotalSupply))
+	}
+	return nil
+}
+
+func cmdTransfer(args []string) error {
+	var o options
+	fs := newFlagSet("transfer", &o)
+	tokenFlag := fs.String("token", "", "address or symbol of the token to transfer (defaults to the fee asset)")
+	tokenIDFlag := fs.String("token-id", "", "Root Network asset id of the token to transfer, instead of --token")
+	toFlag := fs.String("to", "", "receiver of the transfer")
+	amountFlag := fs.String("amount", "", "amount to transfer in the token's units, such as 12.5 or 12.5 SYLO")
+	dryRun := registerDryRun(fs, &o, "transfer")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := checkDryRun(&o, *dryRun); err != nil {
+		return err
+	}
+
+	var token common.Address
+	var err error
+	switch {
+	case *tokenFlag != "" && *tokenIDFlag != "":
+		return fmt.Errorf("--token and --token-id cannot both be given")
+	case *tokenIDFlag != "":
+		token, err = parseAssetID("token-id", *tokenIDFlag)
+	case *tokenFlag != "":
+		token, err = o.token("token", *tokenFlag)
+	default:
+		token, err = o.feeAsset()
+	}
+	if err != nil {
+		return err
+	}
+	receiver, err := parseAddress("to", *toFlag)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+
+	s, err := o.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	amount, err := s.parseAmount(ctx, "amount", token, *amountFlag)
+	if err != nil {
+		return err
+	}
+
+	transferData, err := feeproxy.PackTxData(feeproxy.SyloTokenMetaData, "transfer", receiver, amount)
+	if err != nil {
+		return fmt.Errorf("could not derive input bytes: %w", err)
+	}
+
+	log.Printf("Transferring %v (%v) from %v to %v", s.format(ctx, token, amount), token.Hex(), s.opts.From.Hex(), receiver.Hex())
+
+	if *dryRun {
+		return s.dryRun(ctx, token, transferData, nil)
+	}
+	return s.send(ctx, token, transferData)
+}
+
+// callFlags are the flags describing the inner call of a fee proxy call.
+// The input is either given as raw hex, or packed from an ABI, a method name
+// and the remaining command line arguments.
+type callFlags struct {
+	target string
+	data   string
+	abi    string
+	method string
+
+	// contractABI is the ABI loaded from abi, if one was given
+	contractABI *abi.ABI
+}
+
+func (c *callFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.target, "target", "", "address or token symbol of the contract to call")
+	fs.StringVar(&c.data, "data", "", "hex encoded input of the call")
+	fs.StringVar(&c.abi, "abi", "", "ABI JSON file of the target, used with --method and the remaining arguments")
+	fs.StringVar(&c.method, "method", "", "method of the target to call, by name or by full signature to pick an overload, with its arguments following the flags")
+}
+
+// parse returns the target and input of the call.
+func (c *callFlags) parse(o *options, args []string) (common.Address, []byte, error) {
+	target, err := o.token("target", c.target)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+
+	if c.abi == "" {
+		if c.method != "" || len(args) != 0 {
+			return common.Address{}, nil, fmt.Errorf("--method and arguments need --abi")
+		}
+		if c.data == "" {
+			c.data = "0x"
+		}
+		input, err := parseHex("input", c.data)
+		if err != nil {
+			return common.Address{}, nil, err
+		}
+		return target, input, nil
+	}
+
+	if c.data != "" {
+		return common.Address{}, nil, fmt.Errorf("--data cannot be used with --abi")
+	}
+	if c.contractABI, err = feeproxy.LoadABI(c.abi); err != nil {
+		return common.Address{}, nil, err
+	}
+	input, err := feeproxy.PackCall(c.contractABI, c.method, args)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	return target, input, nil
+}
+
+func cmdCall(args []string) error {
+	var o options
+	var c callFlags
+	fs := newFlagSet("call", &o)
+	c.register(fs)
+	dryRun := registerDryRun(fs, &o, "call")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := checkDryRun(&o, *dryRun); err != nil {
+		return err
+	}
+
+	target, input, err := c.parse(&o, fs.Args())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+
+	s, err := o.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		return s.dryRun(ctx, target, input, c.contractABI)
+	}
+	return s.send(ctx, target, input)
+}
+
+func cmdEstimate(args []string) error {
+	var o options
+	var c callFlags
+	fs := newFlagSet("estimate", &o)
+	c.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	target, input, err := c.parse(&o, fs.Args())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+
+	s, err := o.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	maxPayment, err := s.prepare(ctx, target, input)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Gas limit=%v, max payment=%v", s.opts.GasLimit, s.format(ctx, s.asset, maxPayment))
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"go-fee-proxy-reference/feeproxy"
+)
+
+// registerDryRun registers --dry-run for simulating what, and --from so that
+// a dry run needs no key.
+func registerDryRun(fs *flag.FlagSet, o *options, what string) *bool {
+	fs.StringVar(&o.from, "from", "", "address of the sender for --dry-run, so that no key is needed")
+	return fs.Bool("dry-run", false, "simulate the "+what+" and report the outcome without signing or sending it")
+}
+
+// checkDryRun checks that --from is only given for a dry run, as sending
+// needs the sender's key.
+func checkDryRun(o *options, dryRun bool) error {
+	if o.from != "" && !dryRun {
+		return fmt.Errorf("--from can only be used with --dry-run, sending needs the sender's key")
+	}
+	return nil
+}
+
+// dryRun reports what sending a fee proxy call of target with input would
+// do, without signing or sending it. The inner call's return value is
+// decoded with contractABI, or the ERC-20 ABI if it is nil.
+func (s *session) dryRun(ctx context.Context, target common.Address, input []byte, contractABI *abi.ABI) error {
+	maxPayment, err := s.prepare(ctx, target, input)
+	if err != nil {
+		return err
+	}
+	gasPrice, err := s.gasPrice(ctx)
+	if err != nil {
+		return err
+	}
+	gasLimit := s.opts.GasLimit
+	feeXRP := new(big.Int).Mul(new(big.Int).SetUint64(gasLimit), gasPrice)
+	feeAsset, err := s.quoter.AmountIn(ctx, s.asset, feeproxy.GasCostInXRP(gasLimit, gasPrice))
+	if err != nil {
+		return err
+	}
+
+	sim, err := s.client.Simulate(ctx, s.asset, maxPayment, target, input)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Dry run of fee proxy call from %v to %v, nothing was signed or sent", s.opts.From.Hex(), target.Hex())
+	log.Printf("Gas limit=%v, max gas price=%v", gasLimit, gasPrice)
+	log.Printf("Max fee: %v, or %v (%v) before slippage", feeproxy.NativeXRP.FormatAmount(feeXRP), s.format(ctx, s.asset, feeAsset), s.asset.Hex())
+	log.Printf("Max payment: %v", s.format(ctx, s.asset, maxPayment))
+	if !sim.Succeeded {
+		return fmt.Errorf("fee proxy call would fail: %v", sim.Reason)
+	}
+	log.Printf("Fee proxy call would succeed")
+
+	if contractABI == nil {
+		if contractABI, err = feeproxy.SyloTokenMetaData.GetAbi(); err != nil {
+			return fmt.Errorf("could not get contract abi: %v", err)
+		}
+	}
+	ret, err := feeproxy.FormatReturn(contractABI, input, sim.Return)
+	if err != nil {
+		log.Printf("Inner call returned %v", hexutil.Encode(sim.Return))
+		return nil
+	}
+	log.Printf("Inner call %v", ret)
+	return nil
+}
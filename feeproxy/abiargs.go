@@ -0,0 +1,264 @@
+package feeproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// LoadABI reads a contract ABI from a JSON file. The file may hold the ABI
+// itself or a build artifact with an "abi" field, as written by Hardhat and
+// Truffle.
+func LoadABI(path string) (*abi.ABI, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read abi file: %v", err)
+	}
+	var artifact struct {
+		ABI json.RawMessage `json:"abi"`
+	}
+	if err := json.Unmarshal(b, &artifact); err == nil && len(artifact.ABI) != 0 {
+		b = artifact.ABI
+	}
+	parsed, err := abi.JSON(strings.NewReader(string(b)))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse abi file %v: %v", path, err)
+	}
+	return &parsed, nil
+}
+
+// PackCall packs a call of method on a contract with contractABI, parsing
+// each argument from a string into the type the method expects. Method is a
+// name, or a full signature such as "safeTransferFrom(address,address,uint256,bytes)"
+// to pick one of several overloads. Overloads sharing a name are otherwise
+// told apart by the number of arguments.
+func PackCall(contractABI *abi.ABI, method string, args []string) ([]byte, error) {
+	m, err := findMethod(contractABI, method, len(args))
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != len(m.Inputs) {
+		return nil, fmt.Errorf("method %v takes %v arguments, %v given", m.Sig, len(m.Inputs), len(args))
+	}
+	params := make([]interface{}, len(args))
+	for i, arg := range args {
+		param, err := ParseArg(m.Inputs[i].Type, arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument %v (%v) of %v: %v", i, m.Inputs[i].Name, m.Sig, err)
+		}
+		params[i] = param
+	}
+	// overloads are keyed by their unique name, such as safeTransferFrom0
+	input, err := contractABI.Pack(m.Name, params...)
+	if err != nil {
+		return nil, fmt.Errorf("could not pack method (%s): %v", m.Sig, err)
+	}
+	return input, nil
+}
+
+// findMethod returns the method of contractABI with the given signature, or
+// the overload of the given name taking nargs arguments.
+func findMethod(contractABI *abi.ABI, method string, nargs int) (*abi.Method, error) {
+	if strings.Contains(method, "(") {
+		sig := strings.Join(strings.Fields(method), "")
+		for _, m := range contractABI.Methods {
+			if m.Sig == sig {
+				m := m
+				return &m, nil
+			}
+		}
+		return nil, fmt.Errorf("method %v not found in abi", sig)
+	}
+
+	var overloads []abi.Method
+	for _, m := range contractABI.Methods {
+		if m.RawName == method {
+			overloads = append(overloads, m)
+		}
+	}
+	switch len(overloads) {
+	case 0:
+		return nil, fmt.Errorf("method %v not found in abi", method)
+	case 1:
+		return &overloads[0], nil
+	}
+	var matches []abi.Method
+	sigs := make([]string, len(overloads))
+	for i, m := range overloads {
+		sigs[i] = m.Sig
+		if len(m.Inputs) == nargs {
+			matches = append(matches, m)
+		}
+	}
+	sort.Strings(sigs)
+	if len(matches) != 1 {
+		return nil, fmt.Errorf("method %v has several overloads (%v), give its full signature", method, strings.Join(sigs, ", "))
+	}
+	return &matches[0], nil
+}
+
+// FormatReturn decodes the data returned by a call made with input to a
+// contract with contractABI, as "transfer returned (bool = true)".
+func FormatReturn(contractABI *abi.ABI, input, output []byte) (string, error) {
+	if len(input) < 4 {
+		return "", fmt.Errorf("input too short to select a method")
+	}
+	method, err := contractABI.MethodById(input[:4])
+	if err != nil {
+		return "", err
+	}
+	if len(method.Outputs) == 0 {
+		return method.Name + " returned nothing", nil
+	}
+	values, err := method.Outputs.Unpack(output)
+	if err != nil {
+		return "", fmt.Errorf("could not unpack return of %v: %v", method.Sig, err)
+	}
+	return method.Name + " returned (" + formatArgs(method.Outputs, values) + ")", nil
+}
+
+// formatValue formats a value unpacked from ABI encoded data, with
+// addresses and bytes in hex.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case common.Address:
+		return v.Hex()
+	case []byte:
+		return hexutil.Encode(v)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8 {
+		b := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(b), rv)
+		return hexutil.Encode(b)
+	}
+	return fmt.Sprint(v)
+}
+
+// ParseArg parses s into the Go value abi.Pack expects for t. Numbers may be
+// decimal or 0x prefixed hex, bytes are hex, and arrays and tuples are JSON
+// arrays of their elements.
+func ParseArg(t abi.Type, s string) (interface{}, error) {
+	switch t.T {
+	case abi.AddressTy:
+		if !common.IsHexAddress(s) {
+			return nil, fmt.Errorf("invalid address: %q", s)
+		}
+		return common.HexToAddress(s), nil
+	case abi.BoolTy:
+		return strconv.ParseBool(s)
+	case abi.StringTy:
+		return s, nil
+	case abi.IntTy, abi.UintTy:
+		return parseInt(t, s)
+	case abi.BytesTy:
+		return hexutil.Decode(s)
+	case abi.FixedBytesTy:
+		b, err := hexutil.Decode(s)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > t.Size {
+			return nil, fmt.Errorf("%v bytes given for bytes%v", len(b), t.Size)
+		}
+		v := reflect.New(t.GetType()).Elem()
+		reflect.Copy(v, reflect.ValueOf(b))
+		return v.Interface(), nil
+	case abi.SliceTy, abi.ArrayTy:
+		elems, err := splitJSONArray(s)
+		if err != nil {
+			return nil, err
+		}
+		if t.T == abi.ArrayTy && len(elems) != t.Size {
+			return nil, fmt.Errorf("%v elements given for array of %v", len(elems), t.Size)
+		}
+		var v reflect.Value
+		if t.T == abi.ArrayTy {
+			v = reflect.New(t.GetType()).Elem()
+		} else {
+			v = reflect.MakeSlice(t.GetType(), len(elems), len(elems))
+		}
+		for i, elem := range elems {
+			parsed, err := ParseArg(*t.Elem, elem)
+			if err != nil {
+				return nil, fmt.Errorf("element %v: %v", i, err)
+			}
+			v.Index(i).Set(reflect.ValueOf(parsed))
+		}
+		return v.Interface(), nil
+	case abi.TupleTy:
+		elems, err := splitJSONArray(s)
+		if err != nil {
+			return nil, err
+		}
+		if len(elems) != len(t.TupleElems) {
+			return nil, fmt.Errorf("%v elements given for tuple of %v", len(elems), len(t.TupleElems))
+		}
+		v := reflect.New(t.GetType()).Elem()
+		for i, elem := range elems {
+			parsed, err := ParseArg(*t.TupleElems[i], elem)
+			if err != nil {
+				return nil, fmt.Errorf("field %v (%v): %v", i, t.TupleRawNames[i], err)
+			}
+			v.Field(i).Set(reflect.ValueOf(parsed))
+		}
+		return v.Interface(), nil
+	}
+	return nil, fmt.Errorf("unsupported argument type %v", t.String())
+}
+
+// parseInt parses an integer into the sized Go type abi.Pack expects for
+// 8, 16, 32 and 64 bit integers, or a *big.Int otherwise.
+func parseInt(t abi.Type, s string) (interface{}, error) {
+	n, ok := new(big.Int).SetString(s, 0)
+	if !ok {
+		return nil, fmt.Errorf("invalid integer: %q", s)
+	}
+	if t.T == abi.UintTy {
+		if n.Sign() < 0 || n.BitLen() > t.Size {
+			return nil, fmt.Errorf("%v out of range for %v", s, t.String())
+		}
+	} else {
+		limit := new(big.Int).Lsh(common.Big1, uint(t.Size-1))
+		if n.Cmp(limit) >= 0 || n.Cmp(new(big.Int).Neg(limit)) < 0 {
+			return nil, fmt.Errorf("%v out of range for %v", s, t.String())
+		}
+	}
+
+	kind := t.GetType().Kind()
+	switch kind {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return reflect.ValueOf(n.Uint64()).Convert(t.GetType()).Interface(), nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.ValueOf(n.Int64()).Convert(t.GetType()).Interface(), nil
+	}
+	return n, nil
+}
+
+// splitJSONArray splits a JSON array into the text of its elements, with
+// strings unquoted.
+func splitJSONArray(s string) ([]string, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON array %q: %v", s, err)
+	}
+	elems := make([]string, len(raw))
+	for i, r := range raw {
+		var str string
+		if err := json.Unmarshal(r, &str); err == nil {
+			elems[i] = str
+		} else {
+			elems[i] = string(r)
+		}
+	}
+	return elems, nil
+}
@@ -0,0 +1,62 @@
+package feeproxy
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+const erc721ABI = `[
+	{"type":"function","name":"safeTransferFrom","inputs":[{"name":"from","type":"address"},{"name":"to","type":"address"},{"name":"tokenId","type":"uint256"}],"outputs":[]},
+	{"type":"function","name":"safeTransferFrom","inputs":[{"name":"from","type":"address"},{"name":"to","type":"address"},{"name":"tokenId","type":"uint256"},{"name":"data","type":"bytes"}],"outputs":[]},
+	{"type":"function","name":"approve","inputs":[{"name":"to","type":"address"},{"name":"tokenId","type":"uint256"}],"outputs":[]},
+	{"type":"function","name":"approve","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint128"}],"outputs":[]}
+]`
+
+func TestPackCallOverloads(t *testing.T) {
+	contractABI, err := abi.JSON(strings.NewReader(erc721ABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, to := "0x25451A4de12dcCc2D166922fA938E900fCc4ED24", "0xCCcCCcCC00000C64000000000000000000000000"
+
+	tests := []struct {
+		method string
+		args   []string
+		sig    string
+		err    string
+	}{
+		{"safeTransferFrom", []string{from, to, "7"}, "safeTransferFrom(address,address,uint256)", ""},
+		{"safeTransferFrom", []string{from, to, "7", "0x01"}, "safeTransferFrom(address,address,uint256,bytes)", ""},
+		{"safeTransferFrom(address, address, uint256, bytes)", []string{from, to, "7", "0x"}, "safeTransferFrom(address,address,uint256,bytes)", ""},
+		{"safeTransferFrom", []string{from, to}, "", "several overloads"},
+		{"approve", []string{to, "7"}, "", "give its full signature"},
+		{"approve(address,uint128)", []string{to, "7"}, "approve(address,uint128)", ""},
+		{"safeTransferFrom(address)", []string{from}, "", "not found"},
+		{"burn", []string{"7"}, "", "not found"},
+	}
+	for _, test := range tests {
+		input, err := PackCall(&contractABI, test.method, test.args)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("PackCall(%v, %v) = %v, want an error containing %q", test.method, test.args, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("PackCall(%v, %v): %v", test.method, test.args, err)
+			continue
+		}
+		var id []byte
+		for _, m := range contractABI.Methods {
+			if m.Sig == test.sig {
+				id = m.ID
+			}
+		}
+		if !bytes.HasPrefix(input, id) {
+			t.Errorf("PackCall(%v, %v) called %#x, want %v", test.method, test.args, input[:4], test.sig)
+		}
+	}
+}
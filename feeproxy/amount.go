@@ -0,0 +1,62 @@
+package feeproxy
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ParseUnits parses a decimal amount such as "12.5" into base units of a
+// token with decimals. Amounts with more fractional digits than the token
+// has are rejected rather than rounded.
+func ParseUnits(s string, decimals uint8) (*big.Int, error) {
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	if whole == "" && frac == "" || !isDigits(whole) || !isDigits(frac) {
+		return nil, fmt.Errorf("invalid amount: %q", s)
+	}
+	if len(frac) > int(decimals) {
+		return nil, fmt.Errorf("amount %v has more than %v decimals", s, decimals)
+	}
+
+	digits := whole + frac + strings.Repeat("0", int(decimals)-len(frac))
+	amount, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount: %q", s)
+	}
+	return amount, nil
+}
+
+// FormatUnits formats an amount in base units of a token with decimals as a
+// decimal, without trailing zeros.
+func FormatUnits(amount *big.Int, decimals uint8) string {
+	if amount == nil {
+		return "0"
+	}
+	digits := new(big.Int).Abs(amount).String()
+	if len(digits) <= int(decimals) {
+		digits = strings.Repeat("0", int(decimals)-len(digits)+1) + digits
+	}
+	point := len(digits) - int(decimals)
+	whole, frac := digits[:point], strings.TrimRight(digits[point:], "0")
+
+	s := whole
+	if frac != "" {
+		s += "." + frac
+	}
+	if amount.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
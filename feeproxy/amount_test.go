@@ -0,0 +1,92 @@
+package feeproxy
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	sylo := &TokenInfo{Symbol: "SYLO", Decimals: 18}
+	xrp := &TokenInfo{Symbol: "XRP", Decimals: 6}
+	tests := []struct {
+		token *TokenInfo
+		in    string
+		want  string
+	}{
+		{sylo, "12.5", "12500000000000000000"},
+		{sylo, "12.5 SYLO", "12500000000000000000"},
+		{sylo, "12.5 sylo", "12500000000000000000"},
+		{sylo, "12", "12000000000000000000"},
+		{sylo, ".5", "500000000000000000"},
+		{sylo, "12.", "12000000000000000000"},
+		{sylo, "0.000000000000000001", "1"},
+		{sylo, "1000wei", "1000"},
+		{sylo, "1000 wei", "1000"},
+		{xrp, "12.5", "12500000"},
+		{xrp, "0.000001 XRP", "1"},
+		{sylo, "12.5 XRP", ""},
+		{sylo, "0.0000000000000000001", ""},
+		{xrp, "0.0000001", ""},
+		{sylo, "1.5wei", ""},
+		{sylo, ".", ""},
+		{sylo, "", ""},
+		{sylo, "-1", ""},
+		{sylo, "-1.5 SYLO", ""},
+		{sylo, "1e18", ""},
+		{sylo, "1,000", ""},
+		{sylo, "12.5 SYLO extra", ""},
+	}
+	for _, test := range tests {
+		got, err := test.token.ParseAmount(test.in)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("ParseAmount(%q) = %v, want an error", test.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseAmount(%q): %v", test.in, err)
+			continue
+		}
+		if got.String() != test.want {
+			t.Errorf("ParseAmount(%q) = %v, want %v", test.in, got, test.want)
+		}
+	}
+}
+
+func TestFormatUnits(t *testing.T) {
+	tests := []struct {
+		amount   *big.Int
+		decimals uint8
+		want     string
+	}{
+		{nil, 18, "0"},
+		{big.NewInt(0), 18, "0"},
+		{big.NewInt(0), 0, "0"},
+		{big.NewInt(1), 18, "0.000000000000000001"},
+		{big.NewInt(1), 6, "0.000001"},
+		{big.NewInt(500000), 6, "0.5"},
+		{big.NewInt(12500000), 6, "12.5"},
+		{big.NewInt(12000000), 6, "12"},
+		{big.NewInt(1000), 0, "1000"},
+		{big.NewInt(-1500000), 6, "-1.5"},
+		{new(big.Int).Mul(big.NewInt(125), big.NewInt(1e17)), 18, "12.5"},
+	}
+	for _, test := range tests {
+		if got := FormatUnits(test.amount, test.decimals); got != test.want {
+			t.Errorf("FormatUnits(%v, %v) = %q, want %q", test.amount, test.decimals, got, test.want)
+		}
+	}
+
+	sylo := &TokenInfo{Symbol: "SYLO", Decimals: 18}
+	if got := sylo.FormatAmount(big.NewInt(1)); got != "0.000000000000000001 SYLO" {
+		t.Errorf("FormatAmount(1) = %q", got)
+	}
+	// formatting then parsing gives back the amount
+	for _, amount := range []int64{0, 1, 999, 1e18, 12345678901234567} {
+		parsed, err := sylo.ParseAmount(sylo.FormatAmount(big.NewInt(amount)))
+		if err != nil || parsed.Int64() != amount {
+			t.Errorf("ParseAmount(FormatAmount(%v)) = %v, %v", amount, parsed, err)
+		}
+	}
+}
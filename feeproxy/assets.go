@@ -0,0 +1,49 @@
+package feeproxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// assetPrefix starts the address of the ERC-20 precompile of every Root
+// Network asset. The asset id follows it as a big endian uint32, and the
+// remaining 12 bytes are zero.
+var assetPrefix = []byte{0xcc, 0xcc, 0xcc, 0xcc}
+
+// Asset ids of tokens on the Root Network.
+const (
+	RootAssetID        uint32 = 1
+	XRPAssetID         uint32 = 2
+	SyloAssetID        uint32 = 2148
+	PorciniSyloAssetID uint32 = 3172
+)
+
+// AssetAddress returns the ERC-20 precompile address of a Root Network asset.
+func AssetAddress(id uint32) common.Address {
+	var address common.Address
+	copy(address[:], assetPrefix)
+	binary.BigEndian.PutUint32(address[4:8], id)
+	return address
+}
+
+// AssetID returns the Root Network asset id of an ERC-20 precompile address,
+// or an error if address is not an asset precompile.
+func AssetID(address common.Address) (uint32, error) {
+	if !bytes.Equal(address[:4], assetPrefix) {
+		return 0, fmt.Errorf("%v is not an asset precompile address: it does not start with 0xcccccccc", address.Hex())
+	}
+	if !bytes.Equal(address[8:], make([]byte, common.AddressLength-8)) {
+		return 0, fmt.Errorf("%v is not an asset precompile address: it does not end in zeros", address.Hex())
+	}
+	return binary.BigEndian.Uint32(address[4:8]), nil
+}
+
+// IsAssetAddress reports whether address is the ERC-20 precompile of a Root
+// Network asset.
+func IsAssetAddress(address common.Address) bool {
+	_, err := AssetID(address)
+	return err == nil
+}
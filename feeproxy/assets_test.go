@@ -0,0 +1,53 @@
+package feeproxy
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestAssetAddress(t *testing.T) {
+	tests := []struct {
+		id      uint32
+		address string
+	}{
+		{PorciniSyloAssetID, "0xCCcCCcCC00000C64000000000000000000000000"},
+		{SyloAssetID, "0xCCcCCcCC00000864000000000000000000000000"},
+		{XRPAssetID, "0xCCcCCcCC00000002000000000000000000000000"},
+		{RootAssetID, "0xcCcCCCcc00000001000000000000000000000000"},
+		{0, "0xcCCcCcCc00000000000000000000000000000000"},
+		{0xffffffff, "0xcCCcCcCcFFffffFf000000000000000000000000"},
+	}
+	for _, test := range tests {
+		want := common.HexToAddress(test.address)
+		if got := AssetAddress(test.id); got != want {
+			t.Errorf("AssetAddress(%v) = %v, want %v", test.id, got.Hex(), want.Hex())
+		}
+		id, err := AssetID(want)
+		if err != nil {
+			t.Errorf("AssetID(%v): %v", want.Hex(), err)
+		} else if id != test.id {
+			t.Errorf("AssetID(%v) = %v, want %v", want.Hex(), id, test.id)
+		}
+		if !IsAssetAddress(want) {
+			t.Errorf("IsAssetAddress(%v) = false", want.Hex())
+		}
+	}
+}
+
+func TestAssetIDRejects(t *testing.T) {
+	for _, address := range []string{
+		"0xCCcCCcCb00000C64000000000000000000000000", // wrong prefix
+		"0x0000000000000C64000000000000000000000000", // no prefix
+		"0xCCcCCcCC00000C64000000000000000000000001", // nonzero tail
+		"0xCCcCCcCC00000C64100000000000000000000000", // nonzero tail
+		"0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266", // an account
+	} {
+		if id, err := AssetID(common.HexToAddress(address)); err == nil {
+			t.Errorf("AssetID(%v) = %v, want an error", address, id)
+		}
+		if IsAssetAddress(common.HexToAddress(address)) {
+			t.Errorf("IsAssetAddress(%v) = true", address)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package feeproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DefaultAddress is the address of the fee proxy precompile on the Root Network.
+var DefaultAddress = common.HexToAddress("0x00000000000000000000000000000000000004bb")
+
+// maxNonceRetries is how many times Send retries with a fresh nonce when the
+// node rejects the one reserved from the nonce manager as too low.
+const maxNonceRetries = 3
+
+// Client sends transactions through the fee proxy precompile, paying the
+// transaction fee in a chosen asset instead of XRP.
+type Client struct {
+	backend  bind.ContractBackend
+	address  common.Address
+	feeProxy *FeeProxy
+	opts     *bind.TransactOpts
+	nonces   *NonceManager
+}
+
+// NewClient creates a fee proxy client bound to the precompile at address,
+// signing transactions with opts. Changes made to opts after the client is
+// created apply to subsequent sends.
+func NewClient(address common.Address, backend bind.ContractBackend, opts *bind.TransactOpts) (*Client, error) {
+	if opts == nil {
+		return nil, fmt.Errorf("transact opts must be provided")
+	}
+	feeProxy, err := NewFeeProxy(address, backend)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind fee proxy contract: %v", err)
+	}
+	return &Client{
+		backend:  backend,
+		address:  address,
+		feeProxy: feeProxy,
+		opts:     opts,
+	}, nil
+}
+
+// Address returns the address of the fee proxy the client is bound to.
+func (c *Client) Address() common.Address {
+	return c.address
+}
+
+// From returns the address of the account sending transactions.
+func (c *Client) From() common.Address {
+	return c.opts.From
+}
+
+// Backend returns the contract backend used by the client.
+func (c *Client) Backend() bind.ContractBackend {
+	return c.backend
+}
+
+// SetNonceManager makes the client take the nonce of every transaction from
+// nonces instead of asking the node, so the client can be used to send from
+// many goroutines at once. Nonces should manage the client's account.
+func (c *Client) SetNonceManager(nonces *NonceManager) {
+	c.nonces = nonces
+}
+
+// Pack returns the input bytes for a callWithFeePreferences call.
+func (c *Client) Pack(asset common.Address, maxPayment *big.Int, target common.Address, input []byte) ([]byte, error) {
+	return PackTxData(FeeProxyMetaData, "callWithFeePreferences", asset, maxPayment, target, input)
+}
+
+// EstimateGas asks the node for the gas limit of a callWithFeePreferences
+// call sent from the client's account.
+func (c *Client) EstimateGas(ctx context.Context, asset common.Address, maxPayment *big.Int, target common.Address, input []byte) (uint64, error) {
+	data, err := c.Pack(asset, maxPayment, target, input)
+	if err != nil {
+		return 0, err
+	}
+	msg := ethereum.CallMsg{
+		From: c.opts.From,
+		To:   &c.address,
+		Data: data,
+	}
+	gasLimit, err := c.backend.EstimateGas(ctx, msg)
+	if err != nil {
+		return 0, Classify(fmt.Errorf("could not estimate gas for fee proxy: %w", err))
+	}
+	return gasLimit, nil
+}
+
+// Send calls target with input through the fee proxy, paying at most
+// maxPayment of asset for the transaction fee.
+func (c *Client) Send(ctx context.Context, asset common.Address, maxPayment *big.Int, target common.Address, input []byte) (*types.Transaction, error) {
+	if c.nonces == nil {
+		return c.send(ctx, *c.opts, asset, maxPayment, target, input)
+	}
+
+	// a nonce too low means another sender used it, so resync and try again
+	// with a fresh nonce
+	for attempt := 0; ; attempt++ {
+		nonce, err := c.nonces.Reserve(ctx)
+		if err != nil {
+			return nil, err
+		}
+		opts := *c.opts
+		opts.Nonce = new(big.Int).SetUint64(nonce)
+
+		tx, err := c.send(ctx, opts, asset, maxPayment, target, input)
+		if reportErr := c.nonces.Report(ctx, nonce, err); reportErr != nil {
+			return nil, reportErr
+		}
+		if err == nil || attempt == maxNonceRetries || !errors.Is(err, ErrNonceTooLow) {
+			return tx, err
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, opts bind.TransactOpts, asset common.Address, maxPayment *big.Int, target common.Address, input []byte) (*types.Transaction, error) {
+	opts.Context = ctx
+
+	session := &FeeProxySession{
+		Contract:     c.feeProxy,
+		TransactOpts: opts,
+	}
+
+	tx, err := session.CallWithFeePreferences(asset, maxPayment, target, input)
+	if err != nil {
+		return nil, Classify(fmt.Errorf("failed to send fee proxy transaction: %w", err))
+	}
+	return tx, nil
+}
+
+// PackTxData packs the input bytes for calling method on a contract with the
+// given metadata.
+func PackTxData(contractMetadata *bind.MetaData, method string, params ...interface{}) ([]byte, error) {
+	abi, err := contractMetadata.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("could not get contract abi: %v", err)
+	}
+	input, err := abi.Pack(method, params...)
+	if err != nil {
+		return nil, fmt.Errorf("could not pack method (%s): %v", method, err)
+	}
+	return input, nil
+}
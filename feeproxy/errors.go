@@ -0,0 +1,93 @@
+package feeproxy
+
+import (
+	"errors"
+	"strings"
+)
+
+// Errors returned by the chain are classified as one of these, so they can be
+// checked with errors.Is.
+var (
+	ErrInsufficientBalance   = errors.New("insufficient balance to pay fee")
+	ErrMaxPaymentTooLow      = errors.New("max payment too low")
+	ErrGasLimitTooLow        = errors.New("gas limit too low")
+	ErrGasLimitTooHigh       = errors.New("gas limit too high")
+	ErrGasPriceTooLow        = errors.New("gas price too low")
+	ErrUnknownAsset          = errors.New("unknown fee asset")
+	ErrNonceTooLow           = errors.New("nonce too low")
+	ErrNonceTooHigh          = errors.New("nonce too high")
+	ErrInvalidChainID        = errors.New("invalid chain id")
+	ErrInvalidSignature      = errors.New("invalid signature")
+	ErrInsufficientAllowance = errors.New("insufficient allowance")
+	// ErrInnerInsufficientBalance is a token transfer made by the inner call
+	// failing for lack of balance, as opposed to the fee.
+	ErrInnerInsufficientBalance = errors.New("insufficient token balance for inner call")
+)
+
+// errorPatterns maps substrings of node errors and revert reasons to the
+// error they are classified as. The InvalidTransaction:Custom codes are the
+// transaction validation errors of the Root Network's EVM pallet.
+var errorPatterns = []struct {
+	patterns []string
+	kind     error
+}{
+	{[]string{"custom(1)", "invalid chain id", "invalidchainid"}, ErrInvalidChainID},
+	{[]string{"custom(2)", "invalid signature", "invalidsignature", "invalid sender"}, ErrInvalidSignature},
+	{[]string{"custom(3)", "gas limit too low", "gaslimittoolow", "intrinsic gas too low", "out of gas"}, ErrGasLimitTooLow},
+	{[]string{"custom(4)", "gas limit too high", "gaslimittoohigh", "exceeds block gas limit"}, ErrGasLimitTooHigh},
+	{[]string{"custom(5)", "maxfeepergastoolow", "fee cap less than block base fee", "gas price too low", "underpriced"}, ErrGasPriceTooLow},
+	{[]string{"custom(7)", "balancetoolow", "balancelow", "insufficient funds", "withdrawfailed"}, ErrInsufficientBalance},
+	{[]string{"exceeds balance", "erc20insufficientbalance"}, ErrInnerInsufficientBalance},
+	{[]string{"insufficient allowance", "exceeds allowance"}, ErrInsufficientAllowance},
+	{[]string{"custom(8)", "nonce too low", "txnoncetoolow"}, ErrNonceTooLow},
+	{[]string{"custom(9)", "nonce too high", "txnoncetoohigh"}, ErrNonceTooHigh},
+	{[]string{"excessivesupplyamount", "max payment", "maxpayment"}, ErrMaxPaymentTooLow},
+	{[]string{"invalidassetid", "invalidpaymentasset", "unknown asset", "mustbeenabled", "invalidtradingpath"}, ErrUnknownAsset},
+}
+
+// classifiedError wraps an error from the chain with its classification.
+type classifiedError struct {
+	kind error
+	err  error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+func (e *classifiedError) Is(target error) bool {
+	return target == e.kind
+}
+
+// Classify matches the message and revert reason of err against known chain
+// errors. The returned error matches the classification with errors.Is and
+// unwraps to err. Errors that are not recognised are returned unchanged.
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	kind := classifyMessage(err.Error())
+	if kind == nil {
+		kind = classifyMessage(RevertReason(err))
+	}
+	if kind == nil {
+		return err
+	}
+	return &classifiedError{kind: kind, err: err}
+}
+
+func classifyMessage(msg string) error {
+	msg = strings.ToLower(msg)
+	for _, p := range errorPatterns {
+		for _, pattern := range p.patterns {
+			if strings.Contains(msg, pattern) {
+				return p.kind
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package feeproxy
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want error
+	}{
+		{"InvalidTransaction::Custom(3)", ErrGasLimitTooLow},
+		{"insufficient funds for gas * price + value", ErrInsufficientBalance},
+		{"Module error: assets.BalanceLow", ErrInsufficientBalance},
+		{"execution reverted: ERC20: transfer amount exceeds balance", ErrInnerInsufficientBalance},
+		{"execution reverted: ERC20InsufficientBalance", ErrInnerInsufficientBalance},
+		{"execution reverted: ERC20: insufficient allowance", ErrInsufficientAllowance},
+		{"nonce too low", ErrNonceTooLow},
+		{"replacement transaction underpriced", ErrGasPriceTooLow},
+	}
+	for _, test := range tests {
+		err := Classify(fmt.Errorf("failed to send: %w", errors.New(test.msg)))
+		if !errors.Is(err, test.want) {
+			t.Errorf("Classify(%q) = %v, want %v", test.msg, err, test.want)
+		}
+	}
+
+	unknown := errors.New("connection refused")
+	if err := Classify(unknown); err != unknown {
+		t.Errorf("Classify(%q) = %v, want it unchanged", unknown, err)
+	}
+}
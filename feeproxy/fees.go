@@ -0,0 +1,189 @@
+package feeproxy
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DefaultFeeHistoryBlocks is the number of recent blocks tips are taken from.
+const DefaultFeeHistoryBlocks = 20
+
+// FeePolicy is how aggressively a fee strategy prices transactions.
+type FeePolicy string
+
+const (
+	FeeEconomy FeePolicy = "economy"
+	FeeNormal  FeePolicy = "normal"
+	FeeUrgent  FeePolicy = "urgent"
+)
+
+// feePolicyParams are the settings of a fee policy.
+type feePolicyParams struct {
+	// percentile of recent tips paid
+	tipPercentile float64
+	// percentage of the base fee the fee cap allows for, so the transaction
+	// stays valid while the base fee rises
+	baseFeePercent uint64
+	// percentage of the node's suggested gas price used on chains without a
+	// base fee
+	gasPricePercent uint64
+}
+
+var feePolicies = map[FeePolicy]feePolicyParams{
+	FeeEconomy: {tipPercentile: 10, baseFeePercent: 125, gasPricePercent: 100},
+	FeeNormal:  {tipPercentile: 50, baseFeePercent: 200, gasPricePercent: 110},
+	FeeUrgent:  {tipPercentile: 90, baseFeePercent: 300, gasPricePercent: 150},
+}
+
+// ParseFeePolicy parses the name of a fee policy.
+func ParseFeePolicy(s string) (FeePolicy, error) {
+	policy := FeePolicy(strings.ToLower(s))
+	if _, ok := feePolicies[policy]; !ok {
+		return "", fmt.Errorf("unknown fee policy %q, expected economy, normal or urgent", s)
+	}
+	return policy, nil
+}
+
+// Fees are the gas prices of a transaction. GasPrice is set for legacy
+// transactions, GasFeeCap and GasTipCap for EIP-1559 ones.
+type Fees struct {
+	GasPrice  *big.Int
+	GasFeeCap *big.Int
+	GasTipCap *big.Int
+}
+
+// MaxGasPrice returns the most the transaction can pay per gas, which is
+// what the max payment has to cover.
+func (f *Fees) MaxGasPrice() *big.Int {
+	if f.GasPrice != nil {
+		return f.GasPrice
+	}
+	return f.GasFeeCap
+}
+
+// Apply sets the fees on opts, clearing any set before.
+func (f *Fees) Apply(opts *bind.TransactOpts) {
+	opts.GasPrice = f.GasPrice
+	opts.GasFeeCap = f.GasFeeCap
+	opts.GasTipCap = f.GasTipCap
+}
+
+func (f *Fees) String() string {
+	if f.GasPrice != nil {
+		return fmt.Sprintf("gas price=%v", f.GasPrice)
+	}
+	return fmt.Sprintf("fee cap=%v, tip cap=%v", f.GasFeeCap, f.GasTipCap)
+}
+
+// FeeBackend is the part of a node client used to price transactions.
+// Backends that also implement FeeHistoryReader have tips taken from recent
+// blocks, others use the node's suggested tip.
+type FeeBackend interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+}
+
+// FeeHistoryReader reads fee history with eth_feeHistory.
+type FeeHistoryReader interface {
+	FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error)
+}
+
+// FeeStrategy prices transactions following a fee policy.
+type FeeStrategy struct {
+	backend FeeBackend
+
+	Policy FeePolicy
+	// Legacy prices transactions with a single gas price instead of EIP-1559
+	// fee and tip caps.
+	Legacy bool
+	// Blocks is the number of recent blocks tips are taken from.
+	Blocks uint64
+}
+
+// NewFeeStrategy creates a strategy pricing EIP-1559 transactions with
+// policy.
+func NewFeeStrategy(backend FeeBackend, policy FeePolicy) *FeeStrategy {
+	return &FeeStrategy{
+		backend: backend,
+		Policy:  policy,
+		Blocks:  DefaultFeeHistoryBlocks,
+	}
+}
+
+// Fees returns the fees for a transaction sent now. On chains without a base
+// fee a legacy gas price is returned whatever the strategy.
+func (s *FeeStrategy) Fees(ctx context.Context) (*Fees, error) {
+	params, ok := feePolicies[s.Policy]
+	if !ok {
+		return nil, fmt.Errorf("unknown fee policy %q", s.Policy)
+	}
+
+	head, err := s.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not get latest header: %v", err)
+	}
+	if head.BaseFee == nil {
+		gasPrice, err := s.backend.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not get gas price: %v", err)
+		}
+		return &Fees{GasPrice: percentOf(gasPrice, params.gasPricePercent)}, nil
+	}
+
+	baseFee, tip, err := s.history(ctx, head.BaseFee, params.tipPercentile)
+	if err != nil {
+		return nil, err
+	}
+	feeCap := percentOf(baseFee, params.baseFeePercent)
+	feeCap.Add(feeCap, tip)
+
+	if s.Legacy {
+		return &Fees{GasPrice: feeCap}, nil
+	}
+	return &Fees{GasFeeCap: feeCap, GasTipCap: tip}, nil
+}
+
+// history returns the base fee of the next block and the median tip paid at
+// percentile over recent blocks. If fee history is not available, the base
+// fee of the latest block and the node's suggested tip are used.
+func (s *FeeStrategy) history(ctx context.Context, baseFee *big.Int, percentile float64) (*big.Int, *big.Int, error) {
+	if reader, ok := s.backend.(FeeHistoryReader); ok && s.Blocks > 0 {
+		history, err := reader.FeeHistory(ctx, s.Blocks, nil, []float64{percentile})
+		if err == nil {
+			// the last base fee is that of the next block
+			if n := len(history.BaseFee); n != 0 && history.BaseFee[n-1] != nil {
+				baseFee = history.BaseFee[n-1]
+			}
+			var tips []*big.Int
+			for _, reward := range history.Reward {
+				if len(reward) != 0 && reward[0] != nil {
+					tips = append(tips, reward[0])
+				}
+			}
+			if len(tips) != 0 {
+				sort.Slice(tips, func(i, j int) bool { return tips[i].Cmp(tips[j]) < 0 })
+				return baseFee, new(big.Int).Set(tips[len(tips)/2]), nil
+			}
+		}
+	}
+
+	tip, err := s.backend.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get gas tip cap: %v", err)
+	}
+	return baseFee, tip, nil
+}
+
+// percentOf returns percent percent of x.
+func percentOf(x *big.Int, percent uint64) *big.Int {
+	scaled := new(big.Int).Mul(x, new(big.Int).SetUint64(percent))
+	return scaled.Div(scaled, big.NewInt(100))
+}
@@ -0,0 +1,124 @@
+package feeproxy
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// DefaultGasOverhead is the gas used by the fee proxy on top of the inner
+	// call, covering the swap of the fee asset for XRP.
+	DefaultGasOverhead = 100000
+	// DefaultGasMultiplier is the percentage the estimate is scaled by.
+	DefaultGasMultiplier = 120
+	// DefaultFallbackGasLimit is used when the gas cannot be estimated.
+	DefaultFallbackGasLimit = 250000
+)
+
+// GasEstimateMethod describes how a gas limit was worked out.
+type GasEstimateMethod string
+
+const (
+	// GasEstimateInner means the inner call was estimated against the target
+	// and the proxy overhead was added.
+	GasEstimateInner GasEstimateMethod = "inner call + overhead"
+	// GasEstimateProxy means the fee proxy call was estimated directly. The
+	// node only estimates the outer call, so the overhead is still added.
+	GasEstimateProxy GasEstimateMethod = "fee proxy call + overhead"
+	// GasEstimateFallback means neither estimate succeeded and the fallback
+	// gas limit was used.
+	GasEstimateFallback GasEstimateMethod = "fallback"
+)
+
+// GasEstimate is the gas limit picked for a fee proxy transaction.
+type GasEstimate struct {
+	GasLimit uint64
+	Method   GasEstimateMethod
+	// Err is the estimation error that caused the fallback, if any.
+	Err error
+}
+
+// GasEstimator works out gas limits for fee proxy calls. Estimating the
+// fee proxy call alone returns the cost of a plain transfer (21000), which is
+// rejected by the chain with InvalidTransaction:Custom(3), so the inner call
+// is estimated against its target and the proxy overhead added on top.
+type GasEstimator struct {
+	client *Client
+
+	// Overhead is the gas added to the inner call estimate.
+	Overhead uint64
+	// Multiplier is the percentage the estimate is scaled by.
+	Multiplier uint64
+	// Fallback is the gas limit used when estimation fails. Estimation
+	// errors are returned when it is zero.
+	Fallback uint64
+}
+
+// NewGasEstimator creates a gas estimator for calls made through client,
+// using the default overhead, multiplier and fallback.
+func NewGasEstimator(client *Client) *GasEstimator {
+	return &GasEstimator{
+		client:     client,
+		Overhead:   DefaultGasOverhead,
+		Multiplier: DefaultGasMultiplier,
+		Fallback:   DefaultFallbackGasLimit,
+	}
+}
+
+// Estimate returns the gas limit for calling target with input through the
+// fee proxy. The larger of the inner call and fee proxy estimates is used.
+func (e *GasEstimator) Estimate(ctx context.Context, asset common.Address, maxPayment *big.Int, target common.Address, input []byte) (*GasEstimate, error) {
+	msg := ethereum.CallMsg{
+		From: e.client.From(),
+		To:   &target,
+		Data: input,
+	}
+	inner, innerErr := e.client.Backend().EstimateGas(ctx, msg)
+	proxy, proxyErr := e.client.EstimateGas(ctx, asset, maxPayment, target, input)
+
+	switch {
+	case innerErr == nil && (proxyErr != nil || inner >= proxy):
+		return &GasEstimate{GasLimit: e.scale(inner), Method: GasEstimateInner}, nil
+	case proxyErr == nil:
+		return &GasEstimate{GasLimit: e.scale(proxy), Method: GasEstimateProxy}, nil
+	}
+
+	err := Classify(fmt.Errorf("could not estimate inner call: %w", innerErr))
+	if e.Fallback == 0 {
+		return nil, err
+	}
+	return &GasEstimate{GasLimit: e.Fallback, Method: GasEstimateFallback, Err: err}, nil
+}
+
+// Prepare estimates the gas limit for calling target with input through the
+// fee proxy, paying in asset, and returns it with the max payment quote gives
+// for that gas limit. The fee proxy call needs a max payment to be estimated,
+// so a provisional one is quoted for the fallback gas limit first, or for
+// DefaultFallbackGasLimit if there is no fallback.
+func (e *GasEstimator) Prepare(ctx context.Context, asset common.Address, target common.Address, input []byte, quote func(ctx context.Context, gasLimit uint64) (*big.Int, error)) (*GasEstimate, *big.Int, error) {
+	provisionalGas := e.Fallback
+	if provisionalGas == 0 {
+		provisionalGas = DefaultFallbackGasLimit
+	}
+	provisional, err := quote(ctx, provisionalGas)
+	if err != nil {
+		return nil, nil, err
+	}
+	estimate, err := e.Estimate(ctx, asset, provisional, target, input)
+	if err != nil {
+		return nil, nil, err
+	}
+	maxPayment, err := quote(ctx, estimate.GasLimit)
+	if err != nil {
+		return nil, nil, err
+	}
+	return estimate, maxPayment, nil
+}
+
+func (e *GasEstimator) scale(gas uint64) uint64 {
+	return (gas + e.Overhead) * e.Multiplier / 100
+}
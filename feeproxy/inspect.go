@@ -0,0 +1,101 @@
+package feeproxy
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DecodedCall is a contract call decoded with the ABI of its method.
+type DecodedCall struct {
+	Method *abi.Method
+	Args   []interface{}
+}
+
+// DecodeCall decodes input with the first of abis that has the method it
+// calls.
+func DecodeCall(abis []*abi.ABI, input []byte) (*DecodedCall, error) {
+	if len(input) < 4 {
+		return nil, fmt.Errorf("input too short to select a method")
+	}
+	for _, contractABI := range abis {
+		method, err := contractABI.MethodById(input[:4])
+		if err != nil {
+			continue
+		}
+		args, err := method.Inputs.Unpack(input[4:])
+		if err != nil {
+			return nil, fmt.Errorf("could not unpack arguments of %v: %v", method.Sig, err)
+		}
+		return &DecodedCall{Method: method, Args: args}, nil
+	}
+	return nil, fmt.Errorf("no known abi has a method with id %#x", input[:4])
+}
+
+// String formats the call as "transfer(address to = 0x..., uint256 amount = 5)".
+func (c *DecodedCall) String() string {
+	return c.Method.Name + "(" + formatArgs(c.Method.Inputs, c.Args) + ")"
+}
+
+// formatArgs formats values unpacked from args as "type name = value".
+func formatArgs(args abi.Arguments, values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = args[i].Type.String()
+		if name := args[i].Name; name != "" {
+			parts[i] += " " + name
+		}
+		parts[i] += " = " + formatValue(value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// TokenEvent is an ERC-20 Transfer or Approval event.
+type TokenEvent struct {
+	// Name is Transfer or Approval.
+	Name  string
+	Token common.Address
+	// From is the sender of a transfer or the owner of an approval.
+	From common.Address
+	// To is the receiver of a transfer or the spender of an approval.
+	To    common.Address
+	Value *big.Int
+}
+
+// TokenEvents returns the ERC-20 Transfer and Approval events of any token in
+// the logs of receipt.
+func TokenEvents(receipt *types.Receipt) ([]*TokenEvent, error) {
+	filterer, err := NewSyloTokenFilterer(common.Address{}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind token contract: %v", err)
+	}
+	parsed, err := SyloTokenMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("could not get contract abi: %v", err)
+	}
+	transferID, approvalID := parsed.Events["Transfer"].ID, parsed.Events["Approval"].ID
+
+	var events []*TokenEvent
+	for _, log := range receipt.Logs {
+		if len(log.Topics) == 0 {
+			continue
+		}
+		// logs that fail to parse share the topic but not the layout, such as
+		// ERC-721 transfers with an indexed token id, and are skipped
+		switch log.Topics[0] {
+		case transferID:
+			if transfer, err := filterer.ParseTransfer(*log); err == nil {
+				events = append(events, &TokenEvent{Name: "Transfer", Token: log.Address, From: transfer.From, To: transfer.To, Value: transfer.Value})
+			}
+		case approvalID:
+			if approval, err := filterer.ParseApproval(*log); err == nil {
+				events = append(events, &TokenEvent{Name: "Approval", Token: log.Address, From: approval.Owner, To: approval.Spender, Value: approval.Value})
+			}
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,213 @@
+package feeproxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// DefaultDerivationPath is the derivation path of the first account of a
+// mnemonic.
+const DefaultDerivationPath = "m/44'/60'/0'/0/0"
+
+// KeySource provides the transact opts used to sign fee proxy transactions.
+type KeySource interface {
+	// Address returns the address of the signing account.
+	Address() common.Address
+	// Transactor returns transact opts signing for chainID.
+	Transactor(chainID *big.Int) (*bind.TransactOpts, error)
+}
+
+// privateKeySource signs with a private key held in memory.
+type privateKeySource struct {
+	key *ecdsa.PrivateKey
+}
+
+func (s *privateKeySource) Address() common.Address {
+	return ethcrypto.PubkeyToAddress(s.key.PublicKey)
+}
+
+func (s *privateKeySource) Transactor(chainID *big.Int) (*bind.TransactOpts, error) {
+	opts, err := bind.NewKeyedTransactorWithChainID(s.key, chainID)
+	if err != nil {
+		return nil, fmt.Errorf("could not create keyed transactor: %v", err)
+	}
+	return opts, nil
+}
+
+// KeyFromHex creates a key source from a hex encoded private key.
+func KeyFromHex(hexKey string) (KeySource, error) {
+	sk, err := ethcrypto.HexToECDSA(strings.TrimPrefix(strings.TrimSpace(hexKey), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive private key: %v", err)
+	}
+	return &privateKeySource{key: sk}, nil
+}
+
+// KeyFromEnv creates a key source from a hex encoded private key held in the
+// environment variable name.
+func KeyFromEnv(name string) (KeySource, error) {
+	hexKey, ok := os.LookupEnv(name)
+	if !ok || hexKey == "" {
+		return nil, fmt.Errorf("environment variable %s is not set", name)
+	}
+	return KeyFromHex(hexKey)
+}
+
+// KeyFromFile creates a key source from a file containing a hex encoded
+// private key.
+func KeyFromFile(path string) (KeySource, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read key file: %v", err)
+	}
+	return KeyFromHex(string(b))
+}
+
+// KeyFromMnemonic creates a key source from a BIP-39 mnemonic and optional
+// passphrase, deriving the key at the BIP-32 derivation path.
+func KeyFromMnemonic(mnemonic, passphrase, path string) (KeySource, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(strings.Join(strings.Fields(mnemonic), " "), passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mnemonic: %v", err)
+	}
+	derivationPath, err := accounts.ParseDerivationPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid derivation path: %v", err)
+	}
+	sk, err := deriveKey(seed, derivationPath)
+	if err != nil {
+		return nil, err
+	}
+	return &privateKeySource{key: sk}, nil
+}
+
+// KeyFromMnemonicFile is KeyFromMnemonic with the mnemonic read from a file.
+func KeyFromMnemonicFile(file, passphrase, path string) (KeySource, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read mnemonic file: %v", err)
+	}
+	return KeyFromMnemonic(string(b), passphrase, path)
+}
+
+// deriveKey derives the private key at path from a BIP-32 seed.
+func deriveKey(seed []byte, path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	curveN := ethcrypto.S256().Params().N
+
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	key, chainCode := new(big.Int).SetBytes(sum[:32]), sum[32:]
+	if key.Sign() == 0 || key.Cmp(curveN) >= 0 {
+		return nil, fmt.Errorf("invalid master key derived from seed")
+	}
+
+	for _, index := range path {
+		data := make([]byte, 0, 37)
+		if index >= 0x80000000 {
+			data = append(data, 0)
+			data = append(data, math.PaddedBigBytes(key, 32)...)
+		} else {
+			sk, err := ethcrypto.ToECDSA(math.PaddedBigBytes(key, 32))
+			if err != nil {
+				return nil, fmt.Errorf("could not derive key: %v", err)
+			}
+			data = append(data, ethcrypto.CompressPubkey(&sk.PublicKey)...)
+		}
+		var indexBytes [4]byte
+		binary.BigEndian.PutUint32(indexBytes[:], index)
+		data = append(data, indexBytes[:]...)
+
+		mac := hmac.New(sha512.New, chainCode)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+
+		tweak := new(big.Int).SetBytes(sum[:32])
+		if tweak.Cmp(curveN) >= 0 {
+			return nil, fmt.Errorf("invalid key derived at index %v", index)
+		}
+		key = tweak.Add(tweak, key).Mod(tweak, curveN)
+		if key.Sign() == 0 {
+			return nil, fmt.Errorf("invalid key derived at index %v", index)
+		}
+		chainCode = sum[32:]
+	}
+
+	return ethcrypto.ToECDSA(math.PaddedBigBytes(key, 32))
+}
+
+// keystoreSource signs with an account in a go-ethereum keystore directory.
+type keystoreSource struct {
+	ks      *keystore.KeyStore
+	account accounts.Account
+}
+
+// KeyFromKeystore creates a key source from the account with address in the
+// keystore directory dir, unlocked with password. The address may be empty
+// if the keystore holds a single account.
+func KeyFromKeystore(dir, address, password string) (KeySource, error) {
+	ks := keystore.NewKeyStore(dir, keystore.StandardScryptN, keystore.StandardScryptP)
+
+	var account accounts.Account
+	switch {
+	case address != "":
+		if !common.IsHexAddress(address) {
+			return nil, fmt.Errorf("invalid account address: %q", address)
+		}
+		found, err := ks.Find(accounts.Account{Address: common.HexToAddress(address)})
+		if err != nil {
+			return nil, fmt.Errorf("could not find account %v in keystore: %v", address, err)
+		}
+		account = found
+	case len(ks.Accounts()) == 1:
+		account = ks.Accounts()[0]
+	default:
+		return nil, fmt.Errorf("keystore %v has %v accounts, an account must be given", dir, len(ks.Accounts()))
+	}
+
+	if err := ks.Unlock(account, password); err != nil {
+		return nil, fmt.Errorf("failed to unlock account: %v", err)
+	}
+	return &keystoreSource{ks: ks, account: account}, nil
+}
+
+// KeyFromKeystorePasswordFile is KeyFromKeystore with the password read from
+// a file. Trailing newlines in the file are ignored.
+func KeyFromKeystorePasswordFile(dir, address, passwordFile string) (KeySource, error) {
+	var password string
+	if passwordFile != "" {
+		b, err := ioutil.ReadFile(passwordFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read password file: %v", err)
+		}
+		password = strings.TrimRight(string(b), "\r\n")
+	}
+	return KeyFromKeystore(dir, address, password)
+}
+
+func (s *keystoreSource) Address() common.Address {
+	return s.account.Address
+}
+
+func (s *keystoreSource) Transactor(chainID *big.Int) (*bind.TransactOpts, error) {
+	opts, err := bind.NewKeyStoreTransactorWithChainID(s.ks, s.account, chainID)
+	if err != nil {
+		return nil, fmt.Errorf("could not create keystore transactor: %v", err)
+	}
+	return opts, nil
+}
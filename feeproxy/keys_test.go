@@ -0,0 +1,53 @@
+package feeproxy
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// testMnemonic is the mnemonic of the well known Hardhat and Anvil accounts.
+const testMnemonic = "test test test test test test test test test test test junk"
+
+func TestKeyFromMnemonic(t *testing.T) {
+	tests := []struct {
+		path string
+		want common.Address
+	}{
+		{"m/44'/60'/0'/0/0", common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266")},
+		{"m/44'/60'/0'/0/1", common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")},
+	}
+	for _, test := range tests {
+		source, err := KeyFromMnemonic(testMnemonic, "", test.path)
+		if err != nil {
+			t.Fatalf("%v: %v", test.path, err)
+		}
+		if got := source.Address(); got != test.want {
+			t.Errorf("%v: address = %v, want %v", test.path, got.Hex(), test.want.Hex())
+		}
+		opts, err := source.Transactor(big.NewInt(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if opts.From != test.want {
+			t.Errorf("%v: transactor is for %v, want %v", test.path, opts.From.Hex(), test.want.Hex())
+		}
+	}
+
+	// a passphrase gives a different seed and so a different account
+	source, err := KeyFromMnemonic(testMnemonic, "passphrase", DefaultDerivationPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.Address() == tests[0].want {
+		t.Errorf("passphrase did not change the derived account")
+	}
+
+	if _, err := KeyFromMnemonic("test test test", "", DefaultDerivationPath); err == nil {
+		t.Errorf("invalid mnemonic was accepted")
+	}
+	if _, err := KeyFromMnemonic(testMnemonic, "", "m/44'/x"); err == nil {
+		t.Errorf("invalid derivation path was accepted")
+	}
+}
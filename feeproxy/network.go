@@ -0,0 +1,139 @@
+package feeproxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Network is a profile of the endpoints and addresses of a Root Network
+// chain.
+type Network struct {
+	Name string `json:"name"`
+	RPC  string `json:"rpc"`
+	// ChainID is the chain id the node must report. Zero accepts any chain.
+	ChainID  uint64         `json:"chainId"`
+	FeeProxy common.Address `json:"feeProxy"`
+	Dex      common.Address `json:"dex"`
+	// FeeAsset is the symbol of the token fees are paid in by default.
+	FeeAsset string `json:"feeAsset"`
+	// Tokens maps token symbols to their addresses.
+	Tokens map[string]common.Address `json:"tokens"`
+}
+
+// Networks are the built in network profiles.
+var Networks = map[string]*Network{
+	"porcini": {
+		Name:     "porcini",
+		RPC:      "https://porcini.au.rootnet.app",
+		ChainID:  7672,
+		FeeProxy: DefaultAddress,
+		Dex:      DexAddress,
+		FeeAsset: "SYLO",
+		Tokens: map[string]common.Address{
+			"ROOT": AssetAddress(RootAssetID),
+			"XRP":  XRPAddress,
+			"SYLO": AssetAddress(PorciniSyloAssetID),
+		},
+	},
+	"root": {
+		Name:     "root",
+		RPC:      "https://root.rootnet.live/archive",
+		ChainID:  7668,
+		FeeProxy: DefaultAddress,
+		Dex:      DexAddress,
+		FeeAsset: "SYLO",
+		Tokens: map[string]common.Address{
+			"ROOT": AssetAddress(RootAssetID),
+			"XRP":  XRPAddress,
+			"SYLO": AssetAddress(SyloAssetID),
+		},
+	},
+	"local": {
+		Name:     "local",
+		RPC:      "http://localhost:9933",
+		FeeProxy: DefaultAddress,
+		Dex:      DexAddress,
+		FeeAsset: "XRP",
+		Tokens: map[string]common.Address{
+			"ROOT": AssetAddress(RootAssetID),
+			"XRP":  XRPAddress,
+		},
+	},
+}
+
+// LoadNetworks reads network profiles from a JSON file holding an object of
+// profiles keyed by name. The profiles are merged over the built in ones,
+// replacing any with the same name.
+func LoadNetworks(path string) (map[string]*Network, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read networks file: %v", err)
+	}
+	var loaded map[string]*Network
+	if err := json.Unmarshal(b, &loaded); err != nil {
+		return nil, fmt.Errorf("could not parse networks file %v: %v", path, err)
+	}
+
+	networks := make(map[string]*Network, len(Networks)+len(loaded))
+	for name, network := range Networks {
+		networks[name] = network
+	}
+	for name, network := range loaded {
+		if network.Name == "" {
+			network.Name = name
+		}
+		if network.FeeProxy == (common.Address{}) {
+			network.FeeProxy = DefaultAddress
+		}
+		if network.Dex == (common.Address{}) {
+			network.Dex = DexAddress
+		}
+		networks[name] = network
+	}
+	return networks, nil
+}
+
+// NetworkNames returns the sorted names of networks.
+func NetworkNames(networks map[string]*Network) []string {
+	names := make([]string, 0, len(networks))
+	for name := range networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Token returns the address of the token with symbol, ignoring case.
+func (n *Network) Token(symbol string) (common.Address, bool) {
+	for s, address := range n.Tokens {
+		if strings.EqualFold(s, symbol) {
+			return address, true
+		}
+	}
+	return common.Address{}, false
+}
+
+// ChainIDReader is implemented by node clients that report their chain id.
+type ChainIDReader interface {
+	ChainID(ctx context.Context) (*big.Int, error)
+}
+
+// CheckChainID returns the chain id reported by the node, or an error if it
+// does not match the network.
+func (n *Network) CheckChainID(ctx context.Context, node ChainIDReader) (*big.Int, error) {
+	chainID, err := node.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get chain id: %v", err)
+	}
+	if n.ChainID != 0 && chainID.Cmp(new(big.Int).SetUint64(n.ChainID)) != 0 {
+		return nil, fmt.Errorf("node reports chain id %v, but network %v expects %v", chainID, n.Name, n.ChainID)
+	}
+	return chainID, nil
+}
@@ -0,0 +1,158 @@
+package feeproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// NonceBackend is the part of a node client used to look up nonces.
+type NonceBackend interface {
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+}
+
+// DefaultNonceSyncInterval is how often a nonce manager checks the node for
+// gaps left by dropped transactions by default.
+const DefaultNonceSyncInterval = 30 * time.Second
+
+// NonceManager hands out nonces for an account in-process, so many
+// goroutines can send from the same account without asking the node for the
+// pending nonce each time and colliding. It is safe for concurrent use.
+type NonceManager struct {
+	// SyncInterval is how long a reservation trusts the last pending nonce
+	// fetched from the node before fetching it again. Resyncing finds gaps
+	// left by transactions the node dropped, which would otherwise hold up
+	// every later nonce. Zero only resyncs on first use and nonce errors.
+	SyncInterval time.Duration
+
+	backend NonceBackend
+	account common.Address
+
+	mu       sync.Mutex
+	synced   bool
+	lastSync time.Time
+	next     uint64
+	// free holds nonces below next that must be used before next, either
+	// because they were reserved and released or because the node reports a
+	// gap. It is kept sorted.
+	free []uint64
+	// held holds the nonces reserved and not yet reported, which must not be
+	// handed out again even if the node has not seen them yet.
+	held map[uint64]bool
+}
+
+// NewNonceManager creates a nonce manager for account. The pending nonce is
+// fetched from backend on first use.
+func NewNonceManager(backend NonceBackend, account common.Address) *NonceManager {
+	return &NonceManager{
+		SyncInterval: DefaultNonceSyncInterval,
+		backend:      backend,
+		account:      account,
+		held:         make(map[uint64]bool),
+	}
+}
+
+// Reserve returns the nonce to use for the next transaction. Released nonces
+// and gaps are filled first, lowest first. Every reserved nonce must be
+// passed to Report once the transaction has been sent or has failed.
+func (m *NonceManager) Reserve(ctx context.Context) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.synced || (m.SyncInterval > 0 && time.Since(m.lastSync) >= m.SyncInterval) {
+		if err := m.resync(ctx); err != nil {
+			return 0, err
+		}
+	}
+	var nonce uint64
+	if len(m.free) != 0 {
+		nonce = m.free[0]
+		m.free = m.free[1:]
+	} else {
+		nonce = m.next
+		m.next++
+	}
+	m.held[nonce] = true
+	return nonce, nil
+}
+
+// Report records the outcome of sending a transaction with nonce. A nonce
+// the node rejected is released for reuse, and if it was rejected as too low
+// the manager resyncs with the node instead. A nonce whose transaction may
+// have reached the node, as when the request timed out, is not reused
+// unless a later resync finds the node does not have it.
+func (m *NonceManager) Report(ctx context.Context, nonce uint64, err error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.held, nonce)
+	switch {
+	case err == nil || maybeSent(err):
+		return nil
+	case errors.Is(Classify(err), ErrNonceTooLow):
+		return m.resync(ctx)
+	}
+	m.release(nonce)
+	return nil
+}
+
+// maybeSent reports whether err leaves it unknown if the node received the
+// transaction, because the request timed out or the connection failed.
+func maybeSent(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr)
+}
+
+// Resync updates the manager with the pending nonce of the node. Nonces sent
+// from elsewhere are skipped, and a gap left by a dropped transaction is
+// filled by the next reservation.
+func (m *NonceManager) Resync(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.resync(ctx)
+}
+
+func (m *NonceManager) resync(ctx context.Context) error {
+	pending, err := m.backend.PendingNonceAt(ctx, m.account)
+	if err != nil {
+		return fmt.Errorf("could not get pending nonce of %v: %v", m.account.Hex(), err)
+	}
+
+	// nonces below the pending nonce have been used
+	i := sort.Search(len(m.free), func(i int) bool { return m.free[i] >= pending })
+	m.free = m.free[i:]
+
+	switch {
+	case !m.synced || pending > m.next:
+		m.next = pending
+	case pending < m.next && !m.held[pending]:
+		// the node is missing a nonce we handed out and nobody is still
+		// sending with it, so the transaction using it was dropped
+		m.release(pending)
+	}
+	m.synced = true
+	m.lastSync = time.Now()
+	return nil
+}
+
+// release marks nonce as free to be reserved again.
+func (m *NonceManager) release(nonce uint64) {
+	if nonce >= m.next {
+		return
+	}
+	i := sort.Search(len(m.free), func(i int) bool { return m.free[i] >= nonce })
+	if i < len(m.free) && m.free[i] == nonce {
+		return
+	}
+	m.free = append(m.free, 0)
+	copy(m.free[i+1:], m.free[i:])
+	m.free[i] = nonce
+}
@@ -0,0 +1,102 @@
+package feeproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// pendingNonce is a NonceBackend returning a fixed pending nonce.
+type pendingNonce uint64
+
+func (p *pendingNonce) PendingNonceAt(context.Context, common.Address) (uint64, error) {
+	return uint64(*p), nil
+}
+
+func reserve(t *testing.T, m *NonceManager, want uint64) {
+	t.Helper()
+	nonce, err := m.Reserve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonce != want {
+		t.Fatalf("Reserve = %v, want %v", nonce, want)
+	}
+}
+
+func report(t *testing.T, m *NonceManager, nonce uint64, err error) {
+	t.Helper()
+	if err := m.Report(context.Background(), nonce, err); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNonceManagerRelease(t *testing.T) {
+	pending := pendingNonce(5)
+	m := NewNonceManager(&pending, common.Address{})
+	reserve(t, m, 5)
+	reserve(t, m, 6)
+	reserve(t, m, 7)
+
+	report(t, m, 6, fmt.Errorf("failed to send: %w", errors.New("insufficient funds for gas * price + value")))
+	reserve(t, m, 6)
+	reserve(t, m, 8)
+}
+
+func TestNonceManagerTooLowKeepsHeldNonces(t *testing.T) {
+	pending := pendingNonce(5)
+	m := NewNonceManager(&pending, common.Address{})
+	reserve(t, m, 5)
+	reserve(t, m, 6)
+	reserve(t, m, 7)
+
+	// 5 was used elsewhere and the node is at 6, which is still being sent
+	pending = 6
+	report(t, m, 5, errors.New("nonce too low"))
+	reserve(t, m, 8)
+
+	// the node has 6 once it is sent, and 7 is still held
+	report(t, m, 6, nil)
+	pending = 7
+	if err := m.Resync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	reserve(t, m, 9)
+}
+
+func TestNonceManagerMaybeSent(t *testing.T) {
+	pending := pendingNonce(5)
+	m := NewNonceManager(&pending, common.Address{})
+	reserve(t, m, 5)
+
+	// the node may have the transaction, so 5 is not handed out again
+	report(t, m, 5, fmt.Errorf("failed to send: %w", context.DeadlineExceeded))
+	reserve(t, m, 6)
+	report(t, m, 6, nil)
+
+	// until a resync finds the node never got it
+	if err := m.Resync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	reserve(t, m, 5)
+}
+
+func TestNonceManagerSyncInterval(t *testing.T) {
+	pending := pendingNonce(5)
+	m := NewNonceManager(&pending, common.Address{})
+	reserve(t, m, 5)
+	report(t, m, 5, nil)
+	reserve(t, m, 6)
+	report(t, m, 6, nil)
+
+	// 5 was dropped, which the next reservation finds once the last sync
+	// is older than the interval
+	reserve(t, m, 7)
+	report(t, m, 7, nil)
+	m.lastSync = time.Now().Add(-m.SyncInterval)
+	reserve(t, m, 5)
+}
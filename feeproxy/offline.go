@@ -0,0 +1,174 @@
+package feeproxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// UnsignedTx is a fee proxy transaction built for signing offline. It holds
+// everything needed to sign it, so signing needs only the key and no node.
+type UnsignedTx struct {
+	From      common.Address `json:"from"`
+	ChainID   *hexutil.Big   `json:"chainId"`
+	Nonce     hexutil.Uint64 `json:"nonce"`
+	Gas       hexutil.Uint64 `json:"gas"`
+	GasPrice  *hexutil.Big   `json:"gasPrice,omitempty"`
+	GasFeeCap *hexutil.Big   `json:"maxFeePerGas,omitempty"`
+	GasTipCap *hexutil.Big   `json:"maxPriorityFeePerGas,omitempty"`
+	To        common.Address `json:"to"`
+	Data      hexutil.Bytes  `json:"data"`
+}
+
+// Build creates the fee proxy transaction Send would send from the client's
+// account on chainID, without signing or sending it. The nonce, gas limit
+// and fees are taken from the client's transact opts, or else from the node.
+func (c *Client) Build(ctx context.Context, chainID *big.Int, asset common.Address, maxPayment *big.Int, target common.Address, input []byte) (*UnsignedTx, error) {
+	opts := *c.opts
+	opts.NoSend = true
+	opts.Signer = func(_ common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		return tx, nil
+	}
+	tx, err := c.send(ctx, opts, asset, maxPayment, target, input)
+	if err != nil {
+		return nil, err
+	}
+
+	unsigned := &UnsignedTx{
+		From:    opts.From,
+		ChainID: (*hexutil.Big)(chainID),
+		Nonce:   hexutil.Uint64(tx.Nonce()),
+		Gas:     hexutil.Uint64(tx.Gas()),
+		To:      c.address,
+		Data:    tx.Data(),
+	}
+	if tx.Type() == types.LegacyTxType {
+		unsigned.GasPrice = (*hexutil.Big)(tx.GasPrice())
+	} else {
+		unsigned.GasFeeCap = (*hexutil.Big)(tx.GasFeeCap())
+		unsigned.GasTipCap = (*hexutil.Big)(tx.GasTipCap())
+	}
+	return unsigned, nil
+}
+
+// Transaction returns the transaction to sign.
+func (u *UnsignedTx) Transaction() (*types.Transaction, error) {
+	if u.ChainID == nil {
+		return nil, fmt.Errorf("unsigned tx has no chain id")
+	}
+	to := u.To
+	switch {
+	case u.GasPrice != nil && u.GasFeeCap == nil && u.GasTipCap == nil:
+		return types.NewTx(&types.LegacyTx{
+			Nonce:    uint64(u.Nonce),
+			GasPrice: u.GasPrice.ToInt(),
+			Gas:      uint64(u.Gas),
+			To:       &to,
+			Value:    new(big.Int),
+			Data:     u.Data,
+		}), nil
+	case u.GasPrice == nil && u.GasFeeCap != nil && u.GasTipCap != nil:
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:   u.ChainID.ToInt(),
+			Nonce:     uint64(u.Nonce),
+			GasTipCap: u.GasTipCap.ToInt(),
+			GasFeeCap: u.GasFeeCap.ToInt(),
+			Gas:       uint64(u.Gas),
+			To:        &to,
+			Value:     new(big.Int),
+			Data:      u.Data,
+		}), nil
+	}
+	return nil, fmt.Errorf("unsigned tx must have either a gas price or a max fee and priority fee")
+}
+
+// Sign signs the transaction with the key of source, which must be the key
+// of the sender.
+func (u *UnsignedTx) Sign(source KeySource) (*types.Transaction, error) {
+	tx, err := u.Transaction()
+	if err != nil {
+		return nil, err
+	}
+	opts, err := source.Transactor(u.ChainID.ToInt())
+	if err != nil {
+		return nil, err
+	}
+	if opts.From != u.From {
+		return nil, fmt.Errorf("tx is from %v but the key is for %v", u.From.Hex(), opts.From.Hex())
+	}
+	signed, err := opts.Signer(u.From, tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign tx: %v", err)
+	}
+	return signed, nil
+}
+
+// Broadcast sends a transaction signed offline to the node.
+func (c *Client) Broadcast(ctx context.Context, tx *types.Transaction) error {
+	if err := c.backend.SendTransaction(ctx, tx); err != nil {
+		return Classify(fmt.Errorf("failed to broadcast tx: %w", err))
+	}
+	return nil
+}
+
+// WriteUnsignedTx writes tx to a JSON file at path.
+func WriteUnsignedTx(path string, tx *UnsignedTx) error {
+	b, err := json.MarshalIndent(tx, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode unsigned tx: %v", err)
+	}
+	if err := ioutil.WriteFile(path, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("could not write unsigned tx: %v", err)
+	}
+	return nil
+}
+
+// LoadUnsignedTx reads a JSON file written by WriteUnsignedTx.
+func LoadUnsignedTx(path string) (*UnsignedTx, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read unsigned tx: %v", err)
+	}
+	var tx UnsignedTx
+	if err := json.Unmarshal(b, &tx); err != nil {
+		return nil, fmt.Errorf("could not parse unsigned tx %v: %v", path, err)
+	}
+	return &tx, nil
+}
+
+// WriteSignedTx writes tx to a file at path as hex encoded raw transaction
+// bytes, as sent with eth_sendRawTransaction.
+func WriteSignedTx(path string, tx *types.Transaction) error {
+	b, err := tx.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("could not encode signed tx: %v", err)
+	}
+	if err := ioutil.WriteFile(path, []byte(hexutil.Encode(b)+"\n"), 0644); err != nil {
+		return fmt.Errorf("could not write signed tx: %v", err)
+	}
+	return nil
+}
+
+// LoadSignedTx reads a file written by WriteSignedTx.
+func LoadSignedTx(path string) (*types.Transaction, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read signed tx: %v", err)
+	}
+	raw, err := hexutil.Decode(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, fmt.Errorf("could not decode signed tx %v: %v", path, err)
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return nil, fmt.Errorf("could not decode signed tx %v: %v", path, err)
+	}
+	return tx, nil
+}
@@ -0,0 +1,163 @@
+package feeproxy
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PreflightError is returned when a fee proxy call fails a check made before
+// it is sent.
+type PreflightError struct {
+	// Check is the check that failed: balance, allowance or simulation.
+	Check  string
+	Reason string
+	// Kind is the classification of the failure, if it is known.
+	Kind error
+}
+
+func (e *PreflightError) Error() string {
+	return fmt.Sprintf("preflight %v check failed: %v", e.Check, e.Reason)
+}
+
+func (e *PreflightError) Unwrap() error {
+	return e.Kind
+}
+
+// tokenTransfer is an ERC-20 transfer or transferFrom made by an inner call.
+type tokenTransfer struct {
+	From   common.Address
+	To     common.Address
+	Amount *big.Int
+}
+
+// unpackTransfer decodes input as an ERC-20 transfer or transferFrom sent by
+// sender, or returns nil if it is neither.
+func unpackTransfer(sender common.Address, input []byte) *tokenTransfer {
+	parsed, err := SyloTokenMetaData.GetAbi()
+	if err != nil || len(input) < 4 {
+		return nil
+	}
+	method, err := parsed.MethodById(input[:4])
+	if err != nil {
+		return nil
+	}
+	args, err := method.Inputs.Unpack(input[4:])
+	if err != nil {
+		return nil
+	}
+	switch method.Name {
+	case "transfer":
+		return &tokenTransfer{
+			From:   sender,
+			To:     *abi.ConvertType(args[0], new(common.Address)).(*common.Address),
+			Amount: *abi.ConvertType(args[1], new(*big.Int)).(**big.Int),
+		}
+	case "transferFrom":
+		return &tokenTransfer{
+			From:   *abi.ConvertType(args[0], new(common.Address)).(*common.Address),
+			To:     *abi.ConvertType(args[1], new(common.Address)).(*common.Address),
+			Amount: *abi.ConvertType(args[2], new(*big.Int)).(**big.Int),
+		}
+	}
+	return nil
+}
+
+// Preflight checks a fee proxy call before it is sent, returning a
+// *PreflightError if it would fail. The sender must hold maxPayment of asset
+// plus any amount of it the inner call transfers, must have an allowance for
+// tokens the inner call transfers from another account, and the call must
+// succeed when simulated with eth_call.
+func (c *Client) Preflight(ctx context.Context, asset common.Address, maxPayment *big.Int, target common.Address, input []byte) error {
+	return c.PreflightFor(ctx, c.From(), asset, maxPayment, target, input)
+}
+
+// PreflightFor checks a fee proxy call as Preflight does, for an inner call
+// made on behalf of owner, such as an ERC-2771 call forwarded for a user. The
+// tokens the inner call transfers are checked against owner, while the
+// sender must still hold maxPayment of asset.
+func (c *Client) PreflightFor(ctx context.Context, owner common.Address, asset common.Address, maxPayment *big.Int, target common.Address, input []byte) error {
+	from := c.From()
+	opts := &bind.CallOpts{Context: ctx, From: from}
+
+	needAsset := new(big.Int).Set(maxPayment)
+	if transfer := unpackTransfer(owner, input); transfer != nil {
+		switch {
+		case transfer.From != owner:
+			if err := c.checkAllowance(opts, target, transfer, owner); err != nil {
+				return err
+			}
+		case target == asset && owner == from:
+			needAsset.Add(needAsset, transfer.Amount)
+		default:
+			if err := c.checkBalance(opts, owner, target, transfer.Amount, "transfer", ErrInnerInsufficientBalance); err != nil {
+				return err
+			}
+		}
+	}
+	if err := c.checkBalance(opts, from, asset, needAsset, "max payment and transfer", ErrInsufficientBalance); err != nil {
+		return err
+	}
+
+	data, err := c.Pack(asset, maxPayment, target, input)
+	if err != nil {
+		return err
+	}
+	msg := ethereum.CallMsg{
+		From: from,
+		To:   &c.address,
+		Data: data,
+	}
+	if _, err := c.backend.CallContract(ctx, msg, nil); err != nil {
+		reason := RevertReason(err)
+		return &PreflightError{Check: "simulation", Reason: reason, Kind: classifyMessage(reason)}
+	}
+	return nil
+}
+
+// checkBalance checks that holder holds at least amount of token, failing
+// with kind if not.
+func (c *Client) checkBalance(opts *bind.CallOpts, holder, token common.Address, amount *big.Int, purpose string, kind error) error {
+	caller, err := NewSyloTokenCaller(token, c.backend)
+	if err != nil {
+		return fmt.Errorf("failed to bind token contract: %v", err)
+	}
+	balance, err := caller.BalanceOf(opts, holder)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve balance of %v: %v", token.Hex(), err)
+	}
+	if balance.Cmp(amount) < 0 {
+		return &PreflightError{
+			Check:  "balance",
+			Reason: fmt.Sprintf("%v holds %v of %v, %v needs %v", holder.Hex(), balance, token.Hex(), purpose, amount),
+			Kind:   kind,
+		}
+	}
+	return nil
+}
+
+// checkAllowance checks that spender may spend the tokens transfer moves
+// from another account.
+func (c *Client) checkAllowance(opts *bind.CallOpts, token common.Address, transfer *tokenTransfer, spender common.Address) error {
+	caller, err := NewSyloTokenCaller(token, c.backend)
+	if err != nil {
+		return fmt.Errorf("failed to bind token contract: %v", err)
+	}
+	allowance, err := caller.Allowance(opts, transfer.From, spender)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve allowance of %v: %v", token.Hex(), err)
+	}
+	if allowance.Cmp(transfer.Amount) < 0 {
+		return &PreflightError{
+			Check:  "allowance",
+			Reason: fmt.Sprintf("%v may spend %v of %v from %v, transfer needs %v", spender.Hex(), allowance, token.Hex(), transfer.From.Hex(), transfer.Amount),
+			Kind:   ErrInsufficientAllowance,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package feeproxy
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	// DexAddress is the address of the DEX precompile on the Root Network.
+	DexAddress = common.HexToAddress("0x000000000000000000000000000000000000DDDD")
+	// XRPAddress is the ERC-20 precompile address of XRP, the asset gas is paid in.
+	XRPAddress = AssetAddress(XRPAssetID)
+)
+
+// DexMetaData contains the part of the DEX precompile ABI used for quoting.
+var DexMetaData = &bind.MetaData{
+	ABI: "[{\"inputs\":[{\"internalType\":\"uint256\",\"name\":\"amountOut\",\"type\":\"uint256\"},{\"internalType\":\"address[]\",\"name\":\"path\",\"type\":\"address[]\"}],\"name\":\"getAmountsIn\",\"outputs\":[{\"internalType\":\"uint256[]\",\"name\":\"amounts\",\"type\":\"uint256[]\"}],\"stateMutability\":\"view\",\"type\":\"function\"}]",
+}
+
+// DefaultSlippageBps is the buffer added to a DEX quote, in basis points.
+const DefaultSlippageBps = 500
+
+// gas is priced with 18 decimals in the EVM, but the XRP asset only has 6
+var xrpDecimalsScale = big.NewInt(1e12)
+
+// Quoter works out how much of a fee asset is needed to pay for a
+// transaction by asking the DEX precompile for a swap quote.
+type Quoter struct {
+	dex         *bind.BoundContract
+	slippageBps uint64
+}
+
+// NewQuoter creates a quoter using the DEX precompile at address. Quotes are
+// increased by slippageBps basis points to allow for price movement before
+// the transaction is included.
+func NewQuoter(address common.Address, caller bind.ContractCaller, slippageBps uint64) (*Quoter, error) {
+	parsed, err := DexMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("could not get dex abi: %v", err)
+	}
+	return &Quoter{
+		dex:         bind.NewBoundContract(address, *parsed, caller, nil, nil),
+		slippageBps: slippageBps,
+	}, nil
+}
+
+// AmountIn returns the amount of asset the DEX requires to swap for
+// xrpAmount, where xrpAmount uses the 6 decimals of the XRP asset.
+func (q *Quoter) AmountIn(ctx context.Context, asset common.Address, xrpAmount *big.Int) (*big.Int, error) {
+	if asset == XRPAddress {
+		return new(big.Int).Set(xrpAmount), nil
+	}
+
+	var out []interface{}
+	err := q.dex.Call(&bind.CallOpts{Context: ctx}, &out, "getAmountsIn", xrpAmount, []common.Address{asset, XRPAddress})
+	if err != nil {
+		return nil, fmt.Errorf("could not get dex quote for %v: %v", asset.Hex(), err)
+	}
+	amounts := *abi.ConvertType(out[0], new([]*big.Int)).(*[]*big.Int)
+	if len(amounts) == 0 {
+		return nil, fmt.Errorf("dex returned an empty quote for %v", asset.Hex())
+	}
+	return amounts[0], nil
+}
+
+// MaxPayment quotes the amount of asset needed to pay for gasLimit gas at
+// gasPrice wei per gas, including the slippage buffer.
+func (q *Quoter) MaxPayment(ctx context.Context, asset common.Address, gasLimit uint64, gasPrice *big.Int) (*big.Int, error) {
+	amountIn, err := q.AmountIn(ctx, asset, GasCostInXRP(gasLimit, gasPrice))
+	if err != nil {
+		return nil, err
+	}
+	return ApplySlippage(amountIn, q.slippageBps), nil
+}
+
+// GasCostInXRP returns the cost of gasLimit gas at gasPrice in units of the
+// XRP asset, rounded up.
+func GasCostInXRP(gasLimit uint64, gasPrice *big.Int) *big.Int {
+	cost := new(big.Int).Mul(new(big.Int).SetUint64(gasLimit), gasPrice)
+	return ceilDiv(cost, xrpDecimalsScale)
+}
+
+// ApplySlippage increases amount by bps basis points, rounded up.
+func ApplySlippage(amount *big.Int, bps uint64) *big.Int {
+	scaled := new(big.Int).Mul(amount, new(big.Int).SetUint64(10000+bps))
+	return ceilDiv(scaled, big.NewInt(10000))
+}
+
+func ceilDiv(x, y *big.Int) *big.Int {
+	q, m := new(big.Int).DivMod(x, y, new(big.Int))
+	if m.Sign() != 0 {
+		q.Add(q, common.Big1)
+	}
+	return q
+}
@@ -0,0 +1,167 @@
+package feeproxy
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+func TestGasCostInXRP(t *testing.T) {
+	tests := []struct {
+		gasLimit uint64
+		gasPrice int64
+		want     int64
+	}{
+		{21000, 1e12, 21000},
+		{21000, 2e12, 42000},
+		{1, 1, 1},
+		{1, 1e12 + 1, 2},
+		{0, 1e12, 0},
+	}
+	for _, test := range tests {
+		got := GasCostInXRP(test.gasLimit, big.NewInt(test.gasPrice))
+		if got.Cmp(big.NewInt(test.want)) != 0 {
+			t.Errorf("GasCostInXRP(%v, %v) = %v, want %v", test.gasLimit, test.gasPrice, got, test.want)
+		}
+	}
+}
+
+func TestApplySlippage(t *testing.T) {
+	tests := []struct {
+		amount int64
+		bps    uint64
+		want   int64
+	}{
+		{10000, 500, 10500},
+		{100, 0, 100},
+		{1, 500, 2},
+		{19, 500, 20},
+		{20, 500, 21},
+		{0, 500, 0},
+	}
+	for _, test := range tests {
+		got := ApplySlippage(big.NewInt(test.amount), test.bps)
+		if got.Cmp(big.NewInt(test.want)) != 0 {
+			t.Errorf("ApplySlippage(%v, %v) = %v, want %v", test.amount, test.bps, got, test.want)
+		}
+	}
+}
+
+func TestCeilDiv(t *testing.T) {
+	tests := []struct {
+		x, y, want int64
+	}{
+		{10, 5, 2},
+		{11, 5, 3},
+		{14, 5, 3},
+		{0, 5, 0},
+		{1, 1e12, 1},
+	}
+	for _, test := range tests {
+		got := ceilDiv(big.NewInt(test.x), big.NewInt(test.y))
+		if got.Cmp(big.NewInt(test.want)) != 0 {
+			t.Errorf("ceilDiv(%v, %v) = %v, want %v", test.x, test.y, got, test.want)
+		}
+	}
+}
+
+// newDexStub starts a node answering getAmountsIn calls to the DEX with
+// amountIn(amountOut), checking the swap path ends in XRP.
+func newDexStub(t *testing.T, amountIn func(amountOut *big.Int) []*big.Int) (*ethclient.Client, *int) {
+	t.Helper()
+	parsed, err := DexMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	method := parsed.Methods["getAmountsIn"]
+	calls := 0
+	server := newRPCStub(t, rpcStub{
+		"eth_call": func(params []json.RawMessage) (interface{}, error) {
+			calls++
+			var msg struct {
+				To   common.Address `json:"to"`
+				Data hexutil.Bytes  `json:"data"`
+			}
+			if err := json.Unmarshal(params[0], &msg); err != nil {
+				return nil, err
+			}
+			if msg.To != DexAddress {
+				t.Errorf("call to %v, want the dex", msg.To.Hex())
+			}
+			args, err := method.Inputs.Unpack(msg.Data[4:])
+			if err != nil {
+				return nil, err
+			}
+			path := *abi.ConvertType(args[1], new([]common.Address)).(*[]common.Address)
+			if len(path) != 2 || path[1] != XRPAddress {
+				t.Errorf("swap path %v does not end in XRP", path)
+			}
+			out, err := method.Outputs.Pack(amountIn(args[0].(*big.Int)))
+			if err != nil {
+				return nil, err
+			}
+			return hexutil.Bytes(out), nil
+		},
+	})
+	client, err := ethclient.Dial(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	return client, &calls
+}
+
+func TestQuoterAmountIn(t *testing.T) {
+	ctx := context.Background()
+	asset := AssetAddress(SyloAssetID)
+	client, calls := newDexStub(t, func(amountOut *big.Int) []*big.Int {
+		return []*big.Int{new(big.Int).Mul(amountOut, big.NewInt(3)), amountOut}
+	})
+	quoter, err := NewQuoter(DexAddress, client, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	amountIn, err := quoter.AmountIn(ctx, asset, big.NewInt(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amountIn.Cmp(big.NewInt(300)) != 0 {
+		t.Errorf("AmountIn = %v, want 300", amountIn)
+	}
+
+	// 21000 gas at 1e12 wei is 21000 XRP units, bought for 63000 plus 5%
+	maxPayment, err := quoter.MaxPayment(ctx, asset, 21000, big.NewInt(1e12))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxPayment.Cmp(big.NewInt(66150)) != 0 {
+		t.Errorf("MaxPayment = %v, want 66150", maxPayment)
+	}
+
+	before := *calls
+	amountIn, err = quoter.AmountIn(ctx, XRPAddress, big.NewInt(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amountIn.Cmp(big.NewInt(100)) != 0 || *calls != before {
+		t.Errorf("AmountIn of XRP = %v after %v dex calls, want 100 without asking the dex", amountIn, *calls-before)
+	}
+}
+
+func TestQuoterAmountInEmptyQuote(t *testing.T) {
+	client, _ := newDexStub(t, func(*big.Int) []*big.Int { return []*big.Int{} })
+	quoter, err := NewQuoter(DexAddress, client, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := quoter.AmountIn(context.Background(), AssetAddress(SyloAssetID), big.NewInt(100)); err == nil {
+		t.Error("AmountIn succeeded with an empty quote")
+	}
+}
@@ -0,0 +1,209 @@
+package feeproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DefaultPollInterval is how often the waiter checks for a receipt.
+const DefaultPollInterval = 2 * time.Second
+
+// ErrTxDropped is returned when a mined transaction was removed by a reorg
+// and not mined again before the context expired.
+var ErrTxDropped = errors.New("transaction dropped by reorg")
+
+// ReceiptBackend is the part of a node client used to wait for receipts.
+type ReceiptBackend interface {
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// Receipt is the outcome of a mined transaction.
+type Receipt struct {
+	TxHash        common.Hash
+	Status        uint64
+	GasUsed       uint64
+	BlockNumber   uint64
+	BlockHash     common.Hash
+	Confirmations uint64
+	// BaseFee is the base fee of the block, or nil before EIP-1559.
+	BaseFee *big.Int
+	// Reorgs is the number of times the transaction was seen mined and then
+	// removed from the chain while waiting.
+	Reorgs int
+	// Receipt is the raw receipt returned by the node.
+	Receipt *types.Receipt
+}
+
+// Succeeded reports whether the transaction executed successfully.
+func (r *Receipt) Succeeded() bool {
+	return r.Status == types.ReceiptStatusSuccessful
+}
+
+// GasPrice returns the price per gas tx paid in the block it was mined in.
+func (r *Receipt) GasPrice(tx *types.Transaction) *big.Int {
+	if r.BaseFee == nil {
+		return tx.GasPrice()
+	}
+	tip, _ := tx.EffectiveGasTip(r.BaseFee)
+	return tip.Add(tip, r.BaseFee)
+}
+
+// Waiter waits for transactions to be mined and confirmed.
+type Waiter struct {
+	backend ReceiptBackend
+
+	// Confirmations is the number of blocks, including the one the
+	// transaction was mined in, to wait for.
+	Confirmations uint64
+	// PollInterval is how often the backend is polled.
+	PollInterval time.Duration
+}
+
+// NewWaiter creates a waiter that waits for one confirmation.
+func NewWaiter(backend ReceiptBackend) *Waiter {
+	return &Waiter{
+		backend:       backend,
+		Confirmations: 1,
+		PollInterval:  DefaultPollInterval,
+	}
+}
+
+// Wait polls until the transaction with hash is mined with enough
+// confirmations, or ctx is done.
+func (w *Waiter) Wait(ctx context.Context, hash common.Hash) (*Receipt, error) {
+	return w.WaitAny(ctx, hash)
+}
+
+// WaitAny polls until one of the transactions with hashes is mined with
+// enough confirmations, or ctx is done. The hashes should be of
+// transactions replacing each other, so at most one of them can be mined;
+// the receipt's TxHash tells which. Errors name the first hash.
+func (w *Waiter) WaitAny(ctx context.Context, hashes ...common.Hash) (*Receipt, error) {
+	if len(hashes) == 0 {
+		return nil, fmt.Errorf("no transactions to wait for")
+	}
+	hash := hashes[0]
+
+	var (
+		mined  common.Hash
+		reorgs int
+	)
+
+	ticker := time.NewTicker(w.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		receipt, err := w.checkAny(ctx, hashes)
+		switch {
+		case err != nil:
+			return nil, err
+		case receipt == nil && mined != (common.Hash{}):
+			// previously mined block is no longer canonical
+			reorgs++
+			mined = common.Hash{}
+		case receipt != nil:
+			if mined != (common.Hash{}) && mined != receipt.BlockHash {
+				reorgs++
+			}
+			mined = receipt.BlockHash
+			if receipt.Confirmations >= w.Confirmations {
+				receipt.Reorgs = reorgs
+				return receipt, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if reorgs > 0 && mined == (common.Hash{}) {
+				return nil, fmt.Errorf("failed to wait for tx %v: %w", hash.Hex(), ErrTxDropped)
+			}
+			return nil, fmt.Errorf("failed to wait for tx %v: %w", hash.Hex(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check returns the receipt of the transaction if it is mined in a canonical
+// block, or nil if it is still pending. It does not wait.
+func (w *Waiter) Check(ctx context.Context, hash common.Hash) (*Receipt, error) {
+	receipt, err := w.backend.TransactionReceipt(ctx, hash)
+	if errors.Is(err, ethereum.NotFound) || (err == nil && receipt == nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, w.pollError(ctx, hash, err)
+	}
+
+	// make sure the block the receipt refers to has not been reorged out
+	header, err := w.backend.HeaderByNumber(ctx, receipt.BlockNumber)
+	if err != nil {
+		return nil, w.pollError(ctx, hash, err)
+	}
+	if header.Hash() != receipt.BlockHash {
+		return nil, nil
+	}
+
+	head, err := w.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, w.pollError(ctx, hash, err)
+	}
+
+	var confirmations uint64
+	if head.Number.Cmp(receipt.BlockNumber) >= 0 {
+		confirmations = new(big.Int).Sub(head.Number, receipt.BlockNumber).Uint64() + 1
+	}
+
+	return &Receipt{
+		TxHash:        hash,
+		Status:        receipt.Status,
+		GasUsed:       receipt.GasUsed,
+		BlockNumber:   receipt.BlockNumber.Uint64(),
+		BlockHash:     receipt.BlockHash,
+		Confirmations: confirmations,
+		BaseFee:       header.BaseFee,
+		Receipt:       receipt,
+	}, nil
+}
+
+// checkAny returns the receipt of the first of hashes mined in a canonical
+// block, or nil if they are all pending.
+func (w *Waiter) checkAny(ctx context.Context, hashes []common.Hash) (*Receipt, error) {
+	for _, hash := range hashes {
+		receipt, err := w.Check(ctx, hash)
+		if err != nil || receipt != nil {
+			return receipt, err
+		}
+	}
+	return nil, nil
+}
+
+func (w *Waiter) pollError(ctx context.Context, hash common.Hash, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("failed to wait for tx %v: %w", hash.Hex(), ctx.Err())
+	}
+	return fmt.Errorf("failed to get receipt of tx %v: %v", hash.Hex(), err)
+}
+
+// TokensSent returns the total amount of token transferred from sender in
+// the logs of receipt.
+func TokensSent(receipt *types.Receipt, token, sender common.Address) (*big.Int, error) {
+	events, err := TokenEvents(receipt)
+	if err != nil {
+		return nil, err
+	}
+	total := new(big.Int)
+	for _, event := range events {
+		if event.Name == "Transfer" && event.Token == token && event.From == sender {
+			total.Add(total, event.Value)
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,85 @@
+package feeproxy
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/external"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// remoteSource signs with an account held by an external signer, such as
+// clef, using its account_signTransaction JSON-RPC method. The key never
+// enters this process.
+type remoteSource struct {
+	signer  *external.ExternalSigner
+	account accounts.Account
+}
+
+// KeyFromRemoteSigner creates a key source that signs through the external
+// signer at endpoint, using the account with address. The address may be
+// empty if the signer holds a single account.
+func KeyFromRemoteSigner(endpoint, address string) (KeySource, error) {
+	signer, err := external.NewExternalSigner(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to external signer: %v", err)
+	}
+
+	var account accounts.Account
+	switch available := signer.Accounts(); {
+	case address != "":
+		if !common.IsHexAddress(address) {
+			return nil, fmt.Errorf("invalid account address: %q", address)
+		}
+		account = accounts.Account{Address: common.HexToAddress(address)}
+		if !signer.Contains(account) {
+			return nil, fmt.Errorf("external signer does not hold account %v", address)
+		}
+	case len(available) == 1:
+		account = available[0]
+	default:
+		return nil, fmt.Errorf("external signer has %v accounts, an account must be given", len(available))
+	}
+
+	return &remoteSource{signer: signer, account: account}, nil
+}
+
+func (s *remoteSource) Address() common.Address {
+	return s.account.Address
+}
+
+func (s *remoteSource) Transactor(chainID *big.Int) (*bind.TransactOpts, error) {
+	signer := types.LatestSignerForChainID(chainID)
+	return &bind.TransactOpts{
+		From: s.account.Address,
+		Signer: func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if address != s.account.Address {
+				return nil, bind.ErrNotAuthorized
+			}
+			signed, err := s.signer.SignTx(s.account, tx, chainID)
+			if err != nil {
+				return nil, fmt.Errorf("external signer failed to sign transaction: %v", err)
+			}
+			return checkRemoteSignature(signer, s.account.Address, tx, signed)
+		},
+	}, nil
+}
+
+// checkRemoteSignature makes sure the external signer signed the transaction
+// it was asked to, from the expected account.
+func checkRemoteSignature(signer types.Signer, from common.Address, tx, signed *types.Transaction) (*types.Transaction, error) {
+	if signer.Hash(tx) != signer.Hash(signed) {
+		return nil, fmt.Errorf("external signer returned a different transaction")
+	}
+	sender, err := types.Sender(signer, signed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature from external signer: %v", err)
+	}
+	if sender != from {
+		return nil, fmt.Errorf("external signer signed from %v, expected %v", sender.Hex(), from.Hex())
+	}
+	return signed, nil
+}
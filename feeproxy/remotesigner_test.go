@@ -0,0 +1,144 @@
+package feeproxy
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// newClefStub starts an external signer holding account, which signs
+// transactions with sign.
+func newClefStub(t *testing.T, account common.Address, sign func(tx *types.Transaction) (*types.Transaction, error)) string {
+	t.Helper()
+	server := newRPCStub(t, rpcStub{
+		"account_version": func([]json.RawMessage) (interface{}, error) {
+			return "6.0.0", nil
+		},
+		"account_list": func([]json.RawMessage) (interface{}, error) {
+			return []common.Address{account}, nil
+		},
+		"account_signTransaction": func(params []json.RawMessage) (interface{}, error) {
+			var args apitypes.SendTxArgs
+			if err := json.Unmarshal(params[0], &args); err != nil {
+				return nil, err
+			}
+			if args.From.Address() != account {
+				t.Errorf("asked to sign from %v, want %v", args.From.Address().Hex(), account.Hex())
+			}
+			signed, err := sign(args.ToTransaction())
+			if err != nil {
+				return nil, err
+			}
+			raw, err := signed.MarshalBinary()
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{"raw": hexutil.Bytes(raw), "tx": signed}, nil
+		},
+	})
+	return server.URL
+}
+
+func TestRemoteSigner(t *testing.T) {
+	key, _ := ethcrypto.GenerateKey()
+	otherKey, _ := ethcrypto.GenerateKey()
+	account := ethcrypto.PubkeyToAddress(key.PublicKey)
+	chainID := big.NewInt(7672)
+	signer := types.LatestSignerForChainID(chainID)
+
+	tests := []struct {
+		name    string
+		sign    func(tx *types.Transaction) (*types.Transaction, error)
+		wantErr string
+	}{
+		{
+			name: "signed",
+			sign: func(tx *types.Transaction) (*types.Transaction, error) {
+				return types.SignTx(tx, signer, key)
+			},
+		},
+		{
+			name: "different transaction",
+			sign: func(tx *types.Transaction) (*types.Transaction, error) {
+				other := types.NewTx(&types.DynamicFeeTx{
+					ChainID:   chainID,
+					Nonce:     tx.Nonce(),
+					GasTipCap: tx.GasTipCap(),
+					GasFeeCap: tx.GasFeeCap(),
+					Gas:       tx.Gas(),
+					To:        &account,
+					Value:     big.NewInt(1e18),
+				})
+				return types.SignTx(other, signer, key)
+			},
+			wantErr: "different transaction",
+		},
+		{
+			name: "wrong account",
+			sign: func(tx *types.Transaction) (*types.Transaction, error) {
+				return types.SignTx(tx, signer, otherKey)
+			},
+			wantErr: "signed from",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source, err := KeyFromRemoteSigner(newClefStub(t, account, test.sign), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if source.Address() != account {
+				t.Fatalf("Address = %v, want %v", source.Address().Hex(), account.Hex())
+			}
+			opts, err := source.Transactor(chainID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tx := types.NewTx(&types.DynamicFeeTx{
+				ChainID:   chainID,
+				Nonce:     3,
+				GasTipCap: big.NewInt(1),
+				GasFeeCap: big.NewInt(2e9),
+				Gas:       150000,
+				To:        &DefaultAddress,
+				Value:     new(big.Int),
+				Data:      []byte{0x25, 0x5a, 0x34, 0x32},
+			})
+			signed, err := opts.Signer(account, tx)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("Signer error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if signer.Hash(signed) != signer.Hash(tx) {
+				t.Error("signed transaction differs from the one given")
+			}
+			if sender, _ := types.Sender(signer, signed); sender != account {
+				t.Errorf("signed by %v, want %v", sender.Hex(), account.Hex())
+			}
+		})
+	}
+}
+
+func TestKeyFromRemoteSignerAccount(t *testing.T) {
+	account := common.HexToAddress("0x25451A4de12dcCc2D166922fA938E900fCc4ED24")
+	url := newClefStub(t, account, nil)
+	if _, err := KeyFromRemoteSigner(url, account.Hex()); err != nil {
+		t.Errorf("KeyFromRemoteSigner with held account: %v", err)
+	}
+	if _, err := KeyFromRemoteSigner(url, DefaultAddress.Hex()); err == nil {
+		t.Error("KeyFromRemoteSigner succeeded for an account the signer does not hold")
+	}
+}
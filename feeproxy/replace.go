@@ -0,0 +1,118 @@
+package feeproxy
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// MinFeeBumpPercent is the least nodes require the fees of a replacement
+// transaction to be raised by, in percent.
+const MinFeeBumpPercent = 10
+
+// CancelGasLimit is the gas used by a plain XRP transfer, as sent by
+// CancelWithXRP.
+const CancelGasLimit = 21000
+
+// BumpFees returns the fees for a transaction replacing tx, raised by at
+// least percent over those of tx and no lower than fees. The replacement has
+// the same type as tx.
+func BumpFees(tx *types.Transaction, fees *Fees, percent uint64) *Fees {
+	bump := func(old, current *big.Int) *big.Int {
+		bumped := percentOf(old, 100+percent)
+		// integer division may round the bump down to nothing
+		if bumped.Cmp(old) <= 0 {
+			bumped.Add(old, big.NewInt(1))
+		}
+		if current != nil && current.Cmp(bumped) > 0 {
+			return new(big.Int).Set(current)
+		}
+		return bumped
+	}
+
+	if tx.Type() == types.LegacyTxType {
+		return &Fees{GasPrice: bump(tx.GasPrice(), fees.MaxGasPrice())}
+	}
+	tip := fees.GasTipCap
+	if tip == nil {
+		tip = fees.GasPrice
+	}
+	bumped := &Fees{
+		GasFeeCap: bump(tx.GasFeeCap(), fees.MaxGasPrice()),
+		GasTipCap: bump(tx.GasTipCap(), tip),
+	}
+	if bumped.GasTipCap.Cmp(bumped.GasFeeCap) > 0 {
+		bumped.GasTipCap = new(big.Int).Set(bumped.GasFeeCap)
+	}
+	return bumped
+}
+
+// Speedup resends the fee proxy call of tx with the same nonce and gas limit
+// but higher fees, paying at most maxPayment for it. Fees should come from
+// BumpFees for the node to accept the replacement.
+func (c *Client) Speedup(ctx context.Context, tx *types.Transaction, fees *Fees, maxPayment *big.Int) (*types.Transaction, error) {
+	if tx.To() == nil || *tx.To() != c.address {
+		return nil, fmt.Errorf("tx %v is not a call to the fee proxy", tx.Hash().Hex())
+	}
+	call, err := UnpackCall(tx.Data())
+	if err != nil {
+		return nil, err
+	}
+
+	opts := *c.opts
+	opts.Nonce = new(big.Int).SetUint64(tx.Nonce())
+	opts.GasLimit = tx.Gas()
+	fees.Apply(&opts)
+	return c.send(ctx, opts, call.Asset, maxPayment, call.Target, call.Input)
+}
+
+// Cancel replaces tx with a fee proxy call with the same nonce that calls the
+// client's account with no input, which does nothing, paying at most
+// maxPayment of asset for it. Unlike CancelWithXRP it needs no XRP. Fees
+// should come from BumpFees for the node to accept the replacement.
+func (c *Client) Cancel(ctx context.Context, tx *types.Transaction, fees *Fees, asset common.Address, maxPayment *big.Int, gasLimit uint64) (*types.Transaction, error) {
+	opts := *c.opts
+	opts.Nonce = new(big.Int).SetUint64(tx.Nonce())
+	opts.GasLimit = gasLimit
+	fees.Apply(&opts)
+	return c.send(ctx, opts, asset, maxPayment, opts.From, nil)
+}
+
+// CancelWithXRP replaces tx with a zero value transfer from the client's
+// account to itself with the same nonce, paid for in XRP. Fees should come
+// from BumpFees for the node to accept the replacement.
+func (c *Client) CancelWithXRP(ctx context.Context, tx *types.Transaction, fees *Fees) (*types.Transaction, error) {
+	from := c.opts.From
+	var cancel *types.Transaction
+	if fees.GasPrice != nil {
+		cancel = types.NewTx(&types.LegacyTx{
+			Nonce:    tx.Nonce(),
+			GasPrice: fees.GasPrice,
+			Gas:      CancelGasLimit,
+			To:       &from,
+			Value:    new(big.Int),
+		})
+	} else {
+		cancel = types.NewTx(&types.DynamicFeeTx{
+			ChainID:   tx.ChainId(),
+			Nonce:     tx.Nonce(),
+			GasTipCap: fees.GasTipCap,
+			GasFeeCap: fees.GasFeeCap,
+			Gas:       CancelGasLimit,
+			To:        &from,
+			Value:     new(big.Int),
+		})
+	}
+
+	signed, err := c.opts.Signer(from, cancel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign cancel transaction: %v", err)
+	}
+	if err := c.backend.SendTransaction(ctx, signed); err != nil {
+		return nil, Classify(fmt.Errorf("failed to send cancel transaction: %w", err))
+	}
+	return signed, nil
+}
@@ -0,0 +1,138 @@
+package feeproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Call holds the arguments of a callWithFeePreferences call.
+type Call struct {
+	Asset      common.Address
+	MaxPayment *big.Int
+	Target     common.Address
+	Input      []byte
+}
+
+// UnpackCall decodes the input bytes of a callWithFeePreferences call.
+func UnpackCall(data []byte) (*Call, error) {
+	parsed, err := FeeProxyMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("could not get contract abi: %v", err)
+	}
+	if len(data) < 4 {
+		return nil, fmt.Errorf("input too short to be a fee proxy call")
+	}
+	method, err := parsed.MethodById(data[:4])
+	if err != nil {
+		return nil, fmt.Errorf("not a fee proxy call: %v", err)
+	}
+	args, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, fmt.Errorf("could not unpack fee proxy call: %v", err)
+	}
+	return &Call{
+		Asset:      *abi.ConvertType(args[0], new(common.Address)).(*common.Address),
+		MaxPayment: *abi.ConvertType(args[1], new(*big.Int)).(**big.Int),
+		Target:     *abi.ConvertType(args[2], new(common.Address)).(*common.Address),
+		Input:      *abi.ConvertType(args[3], new([]byte)).(*[]byte),
+	}, nil
+}
+
+// ExecutionError is returned when a fee proxy transaction was mined but
+// failed to execute.
+type ExecutionError struct {
+	TxHash      common.Hash
+	Target      common.Address
+	Reason      string
+	GasUsed     uint64
+	BlockNumber uint64
+	// Kind is the classification of the revert reason, if it is known.
+	Kind error
+}
+
+func (e *ExecutionError) Error() string {
+	return fmt.Sprintf("fee proxy transaction %v calling %v failed in block %v (gas used=%v): %v",
+		e.TxHash.Hex(), e.Target.Hex(), e.BlockNumber, e.GasUsed, e.Reason)
+}
+
+func (e *ExecutionError) Unwrap() error {
+	return e.Kind
+}
+
+// CheckReceipt returns an *ExecutionError if the receipt of tx shows it
+// failed. The call is replayed from the tx's sender at the block it was
+// mined in to recover the revert reason, so tx need not be c's own.
+func (c *Client) CheckReceipt(ctx context.Context, tx *types.Transaction, receipt *Receipt) error {
+	if receipt.Succeeded() {
+		return nil
+	}
+
+	execErr := &ExecutionError{
+		TxHash:      tx.Hash(),
+		GasUsed:     receipt.GasUsed,
+		BlockNumber: receipt.BlockNumber,
+	}
+	if call, err := UnpackCall(tx.Data()); err == nil {
+		execErr.Target = call.Target
+	}
+
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		// an unsigned tx can only have been built for c's own account
+		from = c.From()
+	}
+	msg := ethereum.CallMsg{
+		From:     from,
+		To:       tx.To(),
+		Gas:      tx.Gas(),
+		GasPrice: tx.GasPrice(),
+		Value:    tx.Value(),
+		Data:     tx.Data(),
+	}
+	if tx.Type() == types.DynamicFeeTxType {
+		msg.GasPrice = nil
+		msg.GasFeeCap, msg.GasTipCap = tx.GasFeeCap(), tx.GasTipCap()
+	}
+	_, err = c.backend.CallContract(ctx, msg, new(big.Int).SetUint64(receipt.BlockNumber))
+	if err != nil {
+		execErr.Reason = RevertReason(err)
+		execErr.Kind = classifyMessage(execErr.Reason)
+	} else {
+		execErr.Reason = "unknown, call succeeded on replay"
+	}
+	if execErr.Kind == nil && receipt.GasUsed >= tx.Gas() {
+		execErr.Kind = ErrGasLimitTooLow
+	}
+	return execErr
+}
+
+// RevertReason extracts the revert reason from an error returned by a call.
+// The message of err is returned if it carries no revert data.
+func RevertReason(err error) string {
+	var dataErr rpc.DataError
+	if !errors.As(err, &dataErr) {
+		return err.Error()
+	}
+	data, ok := dataErr.ErrorData().(string)
+	if !ok {
+		return err.Error()
+	}
+	revert, decodeErr := hexutil.Decode(data)
+	if decodeErr != nil {
+		return err.Error()
+	}
+	reason, unpackErr := abi.UnpackRevert(revert)
+	if unpackErr != nil {
+		return fmt.Sprintf("%v (data=%v)", err.Error(), data)
+	}
+	return reason
+}
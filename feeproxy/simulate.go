@@ -0,0 +1,53 @@
+package feeproxy
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Simulation is the outcome of a fee proxy call run with eth_call.
+type Simulation struct {
+	// Succeeded reports whether the call would succeed if sent now.
+	Succeeded bool
+	// Reason is why the call would fail.
+	Reason string
+	// Kind is the classification of the failure, if it is known.
+	Kind error
+	// Return is the data returned by the inner call.
+	Return []byte
+}
+
+// Simulate runs a fee proxy call from the client's account with eth_call,
+// without signing or sending anything. The fee proxy does not pass back what
+// the inner call returns, so once the fee proxy call succeeds the inner call
+// is run on its own from the same account to read its return data.
+func (c *Client) Simulate(ctx context.Context, asset common.Address, maxPayment *big.Int, target common.Address, input []byte) (*Simulation, error) {
+	data, err := c.Pack(asset, maxPayment, target, input)
+	if err != nil {
+		return nil, err
+	}
+	from := c.From()
+	msg := ethereum.CallMsg{
+		From: from,
+		To:   &c.address,
+		Data: data,
+	}
+	if _, err := c.backend.CallContract(ctx, msg, nil); err != nil {
+		reason := RevertReason(err)
+		return &Simulation{Reason: reason, Kind: classifyMessage(reason)}, nil
+	}
+
+	sim := &Simulation{Succeeded: true}
+	msg = ethereum.CallMsg{
+		From: from,
+		To:   &target,
+		Data: input,
+	}
+	if ret, err := c.backend.CallContract(ctx, msg, nil); err == nil {
+		sim.Return = ret
+	}
+	return sim, nil
+}
@@ -0,0 +1,164 @@
+// Package simulated provides an in-memory chain with a mock fee proxy and DEX
+// at their Root Network addresses, for exercising fee proxy calls without a
+// network.
+package simulated
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+
+	"go-fee-proxy-reference/feeproxy"
+)
+
+const gasLimit = 30000000
+
+var (
+	// xrpBalance is the native balance each account starts with.
+	xrpBalance = new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18))
+	// tokenBalance is the SYLO balance each account starts with.
+	tokenBalance = new(big.Int).Mul(big.NewInt(1000000), big.NewInt(1e18))
+)
+
+// Account is a funded account on the simulated chain.
+type Account struct {
+	Key     *ecdsa.PrivateKey
+	Address common.Address
+}
+
+// Backend is a simulated chain with the mock fee proxy deployed at
+// feeproxy.DefaultAddress, the mock DEX at feeproxy.DexAddress and a SYLO
+// token deployed from the generated binding.
+type Backend struct {
+	*backends.SimulatedBackend
+
+	// Accounts hold XRP and SYLO, and have approved the fee proxy to spend
+	// their SYLO.
+	Accounts []*Account
+	// Token is the address of the SYLO token.
+	Token common.Address
+	// AutoCommit mines a block after every transaction that is sent.
+	AutoCommit bool
+}
+
+// NewBackend creates a simulated chain with n funded accounts.
+func NewBackend(n int) (*Backend, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("at least one account is needed")
+	}
+	feeProxyCode, err := compileAsm(mockFeeProxyAsm)
+	if err != nil {
+		return nil, err
+	}
+	dexCode, err := compileAsm(mockDexAsm)
+	if err != nil {
+		return nil, err
+	}
+
+	alloc := core.GenesisAlloc{
+		feeproxy.DefaultAddress: {Code: feeProxyCode, Balance: common.Big0},
+		feeproxy.DexAddress:     {Code: dexCode, Balance: common.Big0},
+	}
+	accounts := make([]*Account, n)
+	for i := range accounts {
+		key, err := ethcrypto.GenerateKey()
+		if err != nil {
+			return nil, fmt.Errorf("could not generate account key: %v", err)
+		}
+		accounts[i] = &Account{Key: key, Address: ethcrypto.PubkeyToAddress(key.PublicKey)}
+		alloc[accounts[i].Address] = core.GenesisAccount{Balance: xrpBalance}
+	}
+
+	b := &Backend{
+		SimulatedBackend: backends.NewSimulatedBackend(alloc, gasLimit),
+		Accounts:         accounts,
+	}
+	if err := b.setupToken(); err != nil {
+		return nil, err
+	}
+	b.AutoCommit = true
+	return b, nil
+}
+
+// setupToken deploys the SYLO token, funds every account and approves the
+// fee proxy to spend it.
+func (b *Backend) setupToken() error {
+	deployer, err := b.Transactor(b.Accounts[0])
+	if err != nil {
+		return err
+	}
+	address, _, token, err := feeproxy.DeploySyloToken(deployer, b)
+	if err != nil {
+		return fmt.Errorf("failed to deploy sylo token: %v", err)
+	}
+	b.Commit()
+	b.Token = address
+
+	for _, account := range b.Accounts[1:] {
+		if _, err := token.Transfer(deployer, account.Address, tokenBalance); err != nil {
+			return fmt.Errorf("failed to fund %v: %v", account.Address.Hex(), err)
+		}
+	}
+	for _, account := range b.Accounts {
+		opts, err := b.Transactor(account)
+		if err != nil {
+			return err
+		}
+		if _, err := token.Approve(opts, feeproxy.DefaultAddress, math.MaxBig256); err != nil {
+			return fmt.Errorf("failed to approve fee proxy for %v: %v", account.Address.Hex(), err)
+		}
+	}
+	b.Commit()
+	return nil
+}
+
+// ChainID returns the chain id of the simulated chain.
+func (b *Backend) ChainID(ctx context.Context) (*big.Int, error) {
+	return b.Blockchain().Config().ChainID, nil
+}
+
+// Transactor returns transact opts signing for account.
+func (b *Backend) Transactor(account *Account) (*bind.TransactOpts, error) {
+	chainID, _ := b.ChainID(context.Background())
+	opts, err := bind.NewKeyedTransactorWithChainID(account.Key, chainID)
+	if err != nil {
+		return nil, fmt.Errorf("could not create keyed transactor: %v", err)
+	}
+	return opts, nil
+}
+
+// SendTransaction sends tx to the pending block, mining it straight away if
+// AutoCommit is set.
+func (b *Backend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if err := b.SimulatedBackend.SendTransaction(ctx, tx); err != nil {
+		return err
+	}
+	if b.AutoCommit {
+		b.Commit()
+	}
+	return nil
+}
+
+// Network returns a network profile for the simulated chain.
+func (b *Backend) Network() *feeproxy.Network {
+	chainID, _ := b.ChainID(context.Background())
+	return &feeproxy.Network{
+		Name:     "simulated",
+		ChainID:  chainID.Uint64(),
+		FeeProxy: feeproxy.DefaultAddress,
+		Dex:      feeproxy.DexAddress,
+		FeeAsset: "SYLO",
+		Tokens: map[string]common.Address{
+			"SYLO": b.Token,
+		},
+	}
+}
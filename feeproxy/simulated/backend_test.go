@@ -0,0 +1,330 @@
+package simulated
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+
+	"go-fee-proxy-reference/feeproxy"
+)
+
+var oneToken = big.NewInt(1e18)
+
+func newClient(t *testing.T, b *Backend, account *Account) *feeproxy.Client {
+	t.Helper()
+	opts, err := b.Transactor(account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a fixed gas limit so that calls expected to fail are still sent
+	opts.GasLimit = 300000
+	client, err := feeproxy.NewClient(feeproxy.DefaultAddress, b, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func balanceOf(t *testing.T, b *Backend, owner common.Address) *big.Int {
+	t.Helper()
+	token, err := feeproxy.NewSyloTokenCaller(b.Token, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	balance, err := token.BalanceOf(&bind.CallOpts{}, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return balance
+}
+
+func sendTransfer(t *testing.T, b *Backend, client *feeproxy.Client, to common.Address, amount, maxPayment *big.Int) (*types.Transaction, *feeproxy.Receipt) {
+	t.Helper()
+	ctx := context.Background()
+	input, err := feeproxy.PackTxData(feeproxy.SyloTokenMetaData, "transfer", to, amount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := client.Send(ctx, b.Token, maxPayment, b.Token, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipt, err := feeproxy.NewWaiter(b).Check(ctx, tx.Hash())
+	if err != nil || receipt == nil {
+		t.Fatalf("no receipt for %v: %v", tx.Hash().Hex(), err)
+	}
+	return tx, receipt
+}
+
+func TestTransferThroughFeeProxy(t *testing.T) {
+	b, err := NewBackend(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := b.Accounts[1]
+	key, _ := ethcrypto.GenerateKey()
+	receiver := ethcrypto.PubkeyToAddress(key.PublicKey)
+	client := newClient(t, b, sender)
+
+	senderBefore := balanceOf(t, b, sender.Address)
+	proxyBefore := balanceOf(t, b, feeproxy.DefaultAddress)
+	amount := new(big.Int).Mul(big.NewInt(5), oneToken)
+	maxPayment := new(big.Int).Mul(big.NewInt(2), oneToken)
+
+	tx, receipt := sendTransfer(t, b, client, receiver, amount, maxPayment)
+	if err := client.CheckReceipt(context.Background(), tx, receipt); err != nil {
+		t.Fatalf("CheckReceipt: %v", err)
+	}
+
+	if got := balanceOf(t, b, receiver); got.Cmp(amount) != 0 {
+		t.Errorf("receiver holds %v, want %v", got, amount)
+	}
+	// the mock charges the whole max payment as the fee
+	spent := new(big.Int).Sub(senderBefore, balanceOf(t, b, sender.Address))
+	if want := new(big.Int).Add(amount, maxPayment); spent.Cmp(want) != 0 {
+		t.Errorf("sender spent %v, want %v", spent, want)
+	}
+	charged := new(big.Int).Sub(balanceOf(t, b, feeproxy.DefaultAddress), proxyBefore)
+	if charged.Cmp(maxPayment) != 0 {
+		t.Errorf("fee proxy charged %v, want %v", charged, maxPayment)
+	}
+
+	sent, err := feeproxy.TokensSent(receipt.Receipt, b.Token, sender.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := new(big.Int).Add(amount, maxPayment); sent.Cmp(want) != 0 {
+		t.Errorf("TokensSent = %v, want %v", sent, want)
+	}
+}
+
+func TestRevertingInnerCall(t *testing.T) {
+	b, err := NewBackend(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := b.Accounts[1]
+	client := newClient(t, b, sender)
+	before := balanceOf(t, b, sender.Address)
+
+	// more than the sender holds, so the inner transfer reverts
+	amount := new(big.Int).Add(before, oneToken)
+	tx, receipt := sendTransfer(t, b, client, b.Accounts[0].Address, amount, oneToken)
+	if receipt.Succeeded() {
+		t.Fatal("transfer of more than the balance succeeded")
+	}
+
+	err = client.CheckReceipt(context.Background(), tx, receipt)
+	var execErr *feeproxy.ExecutionError
+	if !errors.As(err, &execErr) {
+		t.Fatalf("CheckReceipt = %v, want an *ExecutionError", err)
+	}
+	if execErr.Target != b.Token || execErr.Reason != "ERC20: transfer amount exceeds balance" {
+		t.Errorf("execution error calling %v with reason %q", execErr.Target.Hex(), execErr.Reason)
+	}
+	if !errors.Is(err, feeproxy.ErrInnerInsufficientBalance) {
+		t.Errorf("CheckReceipt = %v, want it classified as %v", err, feeproxy.ErrInnerInsufficientBalance)
+	}
+	// replayed from the tx's sender, not from an account holding enough
+	err = newClient(t, b, b.Accounts[0]).CheckReceipt(context.Background(), tx, receipt)
+	if !errors.As(err, &execErr) || execErr.Reason != "ERC20: transfer amount exceeds balance" {
+		t.Errorf("CheckReceipt by another account = %v", err)
+	}
+	// the revert undoes the fee charge too
+	if got := balanceOf(t, b, sender.Address); got.Cmp(before) != 0 {
+		t.Errorf("sender holds %v after the revert, want %v", got, before)
+	}
+}
+
+func TestMockDexQuote(t *testing.T) {
+	b, err := NewBackend(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quoter, err := feeproxy.NewQuoter(feeproxy.DexAddress, b, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// one XRP in the 6 decimals of the XRP asset buys one 18 decimal token
+	amountIn, err := quoter.AmountIn(context.Background(), b.Token, big.NewInt(1e6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amountIn.Cmp(oneToken) != 0 {
+		t.Errorf("AmountIn = %v, want %v", amountIn, oneToken)
+	}
+}
+
+func TestCancelPaidInFeeAsset(t *testing.T) {
+	b, err := NewBackend(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	sender := b.Accounts[1]
+	client := newClient(t, b, sender)
+	before := balanceOf(t, b, sender.Address)
+
+	// the simulated backend does not replace pending transactions, so cancel
+	// one that was never sent
+	nonce, err := b.PendingNonceAt(ctx, sender.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending := types.NewTx(&types.LegacyTx{Nonce: nonce, GasPrice: big.NewInt(1), Gas: 21000})
+	gasPrice, err := b.SuggestGasPrice(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fees := feeproxy.BumpFees(pending, &feeproxy.Fees{GasPrice: gasPrice}, feeproxy.MinFeeBumpPercent)
+
+	cancel, err := client.Cancel(ctx, pending, fees, b.Token, oneToken, 200000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancel.Nonce() != nonce || *cancel.To() != feeproxy.DefaultAddress {
+		t.Errorf("cancel has nonce %v to %v, want nonce %v to the fee proxy", cancel.Nonce(), cancel.To().Hex(), nonce)
+	}
+	receipt, err := feeproxy.NewWaiter(b).Check(ctx, cancel.Hash())
+	if err != nil || receipt == nil || !receipt.Succeeded() {
+		t.Fatalf("cancel did not succeed: %v", err)
+	}
+	if spent := new(big.Int).Sub(before, balanceOf(t, b, sender.Address)); spent.Cmp(oneToken) != 0 {
+		t.Errorf("cancel cost %v of the fee asset, want %v", spent, oneToken)
+	}
+}
+
+func TestPreflightFor(t *testing.T) {
+	b, err := NewBackend(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	relayer := newClient(t, b, b.Accounts[1])
+	owner := b.Accounts[0].Address
+	key, _ := ethcrypto.GenerateKey()
+	empty := ethcrypto.PubkeyToAddress(key.PublicKey)
+
+	// more than the relayer holds, but not more than the owner
+	amount := new(big.Int).Add(balanceOf(t, b, b.Accounts[1].Address), oneToken)
+	input, err := feeproxy.PackTxData(feeproxy.SyloTokenMetaData, "transfer", empty, amount)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var preflightErr *feeproxy.PreflightError
+	err = relayer.Preflight(ctx, b.Token, oneToken, b.Token, input)
+	if !errors.As(err, &preflightErr) || preflightErr.Check != "balance" {
+		t.Errorf("Preflight of a transfer of the relayer's tokens = %v, want a balance error", err)
+	}
+
+	// forwarded calls transfer the owner's tokens, so the relayer's balance
+	// does not matter
+	forwarded := append(append([]byte{}, input...), owner.Bytes()...)
+	err = relayer.PreflightFor(ctx, owner, b.Token, oneToken, b.Token, forwarded)
+	if errors.As(err, &preflightErr) && preflightErr.Check == "balance" {
+		t.Errorf("PreflightFor checked the relayer's balance: %v", err)
+	}
+
+	forwarded = append(append([]byte{}, input...), empty.Bytes()...)
+	err = relayer.PreflightFor(ctx, empty, b.Token, oneToken, b.Token, forwarded)
+	if !errors.As(err, &preflightErr) || preflightErr.Check != "balance" || !errors.Is(err, feeproxy.ErrInnerInsufficientBalance) {
+		t.Errorf("PreflightFor of a transfer by an owner without tokens = %v, want an inner balance error", err)
+	}
+}
+
+func TestOfflineRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		fees func(gasPrice *big.Int) *feeproxy.Fees
+		typ  uint8
+	}{
+		{"legacy", func(gasPrice *big.Int) *feeproxy.Fees { return &feeproxy.Fees{GasPrice: gasPrice} }, types.LegacyTxType},
+		{"eip-1559", func(gasPrice *big.Int) *feeproxy.Fees {
+			return &feeproxy.Fees{GasFeeCap: new(big.Int).Mul(gasPrice, big.NewInt(2)), GasTipCap: big.NewInt(1)}
+		}, types.DynamicFeeTxType},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := NewBackend(2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+			sender := b.Accounts[1]
+			chainID, err := b.ChainID(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gasPrice, err := b.SuggestGasPrice(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the building side has no key
+			opts := &bind.TransactOpts{From: sender.Address, GasLimit: 300000}
+			test.fees(gasPrice).Apply(opts)
+			builder, err := feeproxy.NewClient(feeproxy.DefaultAddress, b, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, _ := ethcrypto.GenerateKey()
+			receiver := ethcrypto.PubkeyToAddress(key.PublicKey)
+			input, err := feeproxy.PackTxData(feeproxy.SyloTokenMetaData, "transfer", receiver, oneToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			unsigned, err := builder.Build(ctx, chainID, b.Token, oneToken, b.Token, input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dir := t.TempDir()
+			unsignedPath, signedPath := filepath.Join(dir, "unsigned.json"), filepath.Join(dir, "signed.txt")
+			if err := feeproxy.WriteUnsignedTx(unsignedPath, unsigned); err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := feeproxy.LoadUnsignedTx(unsignedPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			source, err := feeproxy.KeyFromHex(hexutil.Encode(ethcrypto.FromECDSA(sender.Key)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			signed, err := loaded.Sign(source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := feeproxy.WriteSignedTx(signedPath, signed); err != nil {
+				t.Fatal(err)
+			}
+			tx, err := feeproxy.LoadSignedTx(signedPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tx.Hash() != signed.Hash() || tx.Type() != test.typ {
+				t.Fatalf("loaded tx %v of type %v, want %v of type %v", tx.Hash().Hex(), tx.Type(), signed.Hash().Hex(), test.typ)
+			}
+
+			if err := builder.Broadcast(ctx, tx); err != nil {
+				t.Fatal(err)
+			}
+			receipt, err := feeproxy.NewWaiter(b).Check(ctx, tx.Hash())
+			if err != nil || receipt == nil || !receipt.Succeeded() {
+				t.Fatalf("broadcast tx did not succeed: %v", err)
+			}
+			if got := balanceOf(t, b, receiver); got.Cmp(oneToken) != 0 {
+				t.Errorf("receiver holds %v, want %v", got, oneToken)
+			}
+		})
+	}
+}
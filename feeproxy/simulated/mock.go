@@ -0,0 +1,173 @@
+package simulated
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/asm"
+)
+
+// mockFeeProxyAsm is the runtime code of the mock fee proxy. It implements
+// callWithFeePreferences(address asset, uint128 maxPayment, address target, bytes input):
+//
+//   - the whole maxPayment is charged in asset with transferFrom, paid to the
+//     fee proxy itself
+//   - input is forwarded to target, with an ERC-20 transfer(to, amount)
+//     rewritten to transferFrom(caller, to, amount) so tokens move from the
+//     caller as they do on the Root Network
+//   - a failed inner call reverts with its return data
+//
+// Callers must approve the fee proxy to spend their tokens for both charges.
+const mockFeeProxyAsm = `
+;; only callWithFeePreferences is supported
+PUSH 0
+CALLDATALOAD
+PUSH 0xe0
+SHR
+PUSH 0x255a3432
+EQ
+JUMPI @call
+PUSH 0
+DUP1
+REVERT
+
+call:
+;; asset.transferFrom(caller, address(this), maxPayment)
+PUSH 0x23b872dd
+PUSH 0xe0
+SHL
+PUSH 0
+MSTORE
+CALLER
+PUSH 4
+MSTORE
+ADDRESS
+PUSH 36
+MSTORE
+PUSH 0x24
+CALLDATALOAD
+PUSH 68
+MSTORE
+PUSH 32
+PUSH 0
+PUSH 100
+PUSH 0
+PUSH 0
+PUSH 4
+CALLDATALOAD
+GAS
+CALL
+ISZERO
+JUMPI @bubble
+
+;; stack: [dataPos, len] of input
+PUSH 0x64
+CALLDATALOAD
+PUSH 4
+ADD
+DUP1
+CALLDATALOAD
+SWAP1
+PUSH 32
+ADD
+
+;; forward anything that is not transfer(to, amount) unchanged
+DUP2
+PUSH 68
+GT
+JUMPI @forward
+DUP1
+CALLDATALOAD
+PUSH 0xe0
+SHR
+PUSH 0xa9059cbb
+EQ
+ISZERO
+JUMPI @forward
+
+;; transferFrom(caller, to, amount)
+PUSH 0x23b872dd
+PUSH 0xe0
+SHL
+PUSH 0
+MSTORE
+CALLER
+PUSH 4
+MSTORE
+PUSH 64
+DUP2
+PUSH 4
+ADD
+PUSH 36
+CALLDATACOPY
+POP
+POP
+PUSH 100
+JUMP @invoke
+
+forward:
+DUP2
+SWAP1
+PUSH 0
+CALLDATACOPY
+
+;; stack: [argsSize]
+invoke:
+PUSH 0
+PUSH 0
+DUP3
+PUSH 0
+PUSH 0
+PUSH 0x44
+CALLDATALOAD
+GAS
+CALL
+ISZERO
+JUMPI @bubble
+STOP
+
+bubble:
+RETURNDATASIZE
+PUSH 0
+DUP1
+RETURNDATACOPY
+RETURNDATASIZE
+PUSH 0
+REVERT
+`
+
+// mockDexAsm is the runtime code of the mock DEX. Any call is treated as
+// getAmountsIn(uint256 amountOut, address[] path) and quotes one whole 18
+// decimal token per XRP, returning [amountOut * 1e12, amountOut].
+const mockDexAsm = `
+PUSH 0x20
+PUSH 0
+MSTORE
+PUSH 2
+PUSH 0x20
+MSTORE
+PUSH 4
+CALLDATALOAD
+DUP1
+PUSH 0xe8d4a51000
+MUL
+PUSH 0x40
+MSTORE
+PUSH 0x60
+MSTORE
+PUSH 0x80
+PUSH 0
+RETURN
+`
+
+// compileAsm compiles EVM assembly into bytecode.
+func compileAsm(source string) ([]byte, error) {
+	compiler := asm.NewCompiler(false)
+	compiler.Feed(asm.Lex([]byte(strings.TrimSpace(source)+"\n"), false))
+	bin, errs := compiler.Compile()
+	if len(errs) != 0 {
+		return nil, fmt.Errorf("could not compile mock contract: %v", errs[0])
+	}
+	return hexutil.Decode("0x" + bin)
+}
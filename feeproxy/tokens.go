@@ -0,0 +1,212 @@
+package feeproxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// NativeXRP describes XRP as the native balance seen by the EVM, which has 18
+// decimals unlike the 6 of the XRP token precompile.
+var NativeXRP = &TokenInfo{Symbol: "XRP", Decimals: 18}
+
+// TokenInfo is the metadata of an ERC-20 token.
+type TokenInfo struct {
+	Address common.Address `json:"address"`
+	// AssetID is the Root Network asset id of the token if its address is an
+	// asset precompile, or zero.
+	AssetID  uint32 `json:"assetId,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Symbol   string `json:"symbol"`
+	Decimals uint8  `json:"decimals"`
+	// TotalSupply is the supply when the token was loaded, or nil if it was
+	// not loaded from the chain.
+	TotalSupply *big.Int `json:"totalSupply,omitempty"`
+}
+
+// ParseAmount parses an amount of the token such as "12.5" or "12.5 SYLO"
+// into base units. A symbol, if given, must be the token's. An amount ending
+// in "wei", such as "1000 wei", is already in base units.
+func (t *TokenInfo) ParseAmount(s string) (*big.Int, error) {
+	fields := strings.Fields(s)
+	switch {
+	case len(fields) == 1 && strings.HasSuffix(fields[0], "wei"):
+		fields = []string{strings.TrimSuffix(fields[0], "wei"), "wei"}
+	case len(fields) == 0 || len(fields) > 2:
+		return nil, fmt.Errorf("invalid amount: %q", s)
+	}
+
+	if len(fields) == 2 {
+		switch {
+		case fields[1] == "wei":
+			return ParseUnits(fields[0], 0)
+		case !strings.EqualFold(fields[1], t.Symbol):
+			return nil, fmt.Errorf("amount %q is not in %v", s, t.Symbol)
+		}
+	}
+	return ParseUnits(fields[0], t.Decimals)
+}
+
+// FormatAmount formats an amount in base units of the token, as "12.5 SYLO".
+func (t *TokenInfo) FormatAmount(amount *big.Int) string {
+	return FormatUnits(amount, t.Decimals) + " " + t.Symbol
+}
+
+// TokenConfig is a token in a static token file. The token is given by
+// address or by Root Network asset id. Tokens without decimals have their
+// metadata loaded from the chain when first used.
+type TokenConfig struct {
+	Symbol   string          `json:"symbol"`
+	Address  *common.Address `json:"address,omitempty"`
+	AssetID  *uint32         `json:"assetId,omitempty"`
+	Name     string          `json:"name,omitempty"`
+	Decimals *uint8          `json:"decimals,omitempty"`
+}
+
+// TokenAddress returns the address of the token.
+func (c *TokenConfig) TokenAddress() (common.Address, error) {
+	switch {
+	case c.Address != nil && c.AssetID != nil:
+		if *c.Address != AssetAddress(*c.AssetID) {
+			return common.Address{}, fmt.Errorf("token %v: address %v is not the address of asset %v", c.Symbol, c.Address.Hex(), *c.AssetID)
+		}
+		return *c.Address, nil
+	case c.Address != nil:
+		return *c.Address, nil
+	case c.AssetID != nil:
+		return AssetAddress(*c.AssetID), nil
+	}
+	return common.Address{}, fmt.Errorf("token %v has no address or asset id", c.Symbol)
+}
+
+// LoadTokens reads a static token file holding a JSON array of tokens.
+func LoadTokens(path string) ([]*TokenConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read tokens file: %v", err)
+	}
+	var tokens []*TokenConfig
+	if err := json.Unmarshal(b, &tokens); err != nil {
+		return nil, fmt.Errorf("could not parse tokens file %v: %v", path, err)
+	}
+	for _, token := range tokens {
+		if token.Symbol == "" {
+			return nil, fmt.Errorf("tokens file %v has a token without a symbol", path)
+		}
+		if _, err := token.TokenAddress(); err != nil {
+			return nil, fmt.Errorf("tokens file %v: %v", path, err)
+		}
+	}
+	return tokens, nil
+}
+
+// TokenRegistry finds tokens by address or symbol. The metadata of a token
+// is read from the chain the first time it is needed and then cached, unless
+// it was registered with its decimals. It is safe for concurrent use.
+type TokenRegistry struct {
+	caller bind.ContractCaller
+
+	mu      sync.Mutex
+	tokens  map[common.Address]*TokenInfo
+	symbols map[string]common.Address
+}
+
+// NewTokenRegistry creates a registry reading token metadata with caller.
+func NewTokenRegistry(caller bind.ContractCaller) *TokenRegistry {
+	return &TokenRegistry{
+		caller:  caller,
+		tokens:  make(map[common.Address]*TokenInfo),
+		symbols: make(map[string]common.Address),
+	}
+}
+
+// Register adds a token to the registry, replacing any token with the same
+// symbol.
+func (r *TokenRegistry) Register(config *TokenConfig) error {
+	address, err := config.TokenAddress()
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.symbols[strings.ToUpper(config.Symbol)] = address
+	if config.Decimals != nil {
+		id, _ := AssetID(address)
+		r.tokens[address] = &TokenInfo{
+			Address:  address,
+			AssetID:  id,
+			Name:     config.Name,
+			Symbol:   config.Symbol,
+			Decimals: *config.Decimals,
+		}
+	}
+	return nil
+}
+
+// Address returns the address of the token with symbol, ignoring case.
+func (r *TokenRegistry) Address(symbol string) (common.Address, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	address, ok := r.symbols[strings.ToUpper(symbol)]
+	return address, ok
+}
+
+// Lookup returns the metadata of a token given by address or symbol.
+func (r *TokenRegistry) Lookup(ctx context.Context, s string) (*TokenInfo, error) {
+	if common.IsHexAddress(s) {
+		return r.Info(ctx, common.HexToAddress(s))
+	}
+	address, ok := r.Address(s)
+	if !ok {
+		return nil, fmt.Errorf("unknown token %q", s)
+	}
+	return r.Info(ctx, address)
+}
+
+// Info returns the metadata of token, reading its name, symbol, decimals
+// and total supply from the chain the first time the token is seen.
+func (r *TokenRegistry) Info(ctx context.Context, token common.Address) (*TokenInfo, error) {
+	r.mu.Lock()
+	info, ok := r.tokens[token]
+	r.mu.Unlock()
+	if ok {
+		return info, nil
+	}
+
+	caller, err := NewSyloTokenCaller(token, r.caller)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind token contract: %v", err)
+	}
+	opts := &bind.CallOpts{Context: ctx}
+	info = &TokenInfo{Address: token}
+	info.AssetID, _ = AssetID(token)
+	if info.Name, err = caller.Name(opts); err != nil {
+		return nil, fmt.Errorf("failed to retrieve name of %v: %v", token.Hex(), err)
+	}
+	if info.Symbol, err = caller.Symbol(opts); err != nil {
+		return nil, fmt.Errorf("failed to retrieve symbol of %v: %v", token.Hex(), err)
+	}
+	if info.Decimals, err = caller.Decimals(opts); err != nil {
+		return nil, fmt.Errorf("failed to retrieve decimals of %v: %v", token.Hex(), err)
+	}
+	if info.TotalSupply, err = caller.TotalSupply(opts); err != nil {
+		return nil, fmt.Errorf("failed to retrieve total supply of %v: %v", token.Hex(), err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[token] = info
+	symbol := strings.ToUpper(info.Symbol)
+	if _, ok := r.symbols[symbol]; !ok {
+		r.symbols[symbol] = token
+	}
+	return info, nil
+}
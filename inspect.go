@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"go-fee-proxy-reference/feeproxy"
+)
+
+// inspector logs a human readable breakdown of a transaction.
+type inspector struct {
+	tokens *feeproxy.TokenRegistry
+	// abis are tried in order to decode inner calls
+	abis []*abi.ABI
+}
+
+func cmdInspect(args []string) error {
+	var o options
+	fs := newFlagSet("inspect", &o)
+	abiFiles := fs.String("abi", "", "comma separated ABI JSON files used to decode inner calls, in addition to the ERC-20 ABI")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected the hash of one transaction")
+	}
+	hash, err := parseHash("transaction", fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	abis, err := loadABIs(*abiFiles)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+
+	evmClient, chainID, err := o.dial(ctx)
+	if err != nil {
+		return err
+	}
+	tokens, err := o.tokenRegistry(evmClient)
+	if err != nil {
+		return err
+	}
+	in := &inspector{tokens: tokens, abis: abis}
+
+	tx, _, err := evmClient.TransactionByHash(ctx, hash)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve tx %v: %v", hash.Hex(), err)
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(chainID), tx)
+	if err != nil {
+		return fmt.Errorf("could not recover sender of tx %v: %v", hash.Hex(), err)
+	}
+
+	log.Printf("Transaction: %v", hash.Hex())
+	log.Printf("From: %v, nonce=%v", sender.Hex(), tx.Nonce())
+	if tx.To() != nil {
+		log.Printf("To: %v", tx.To().Hex())
+	}
+	log.Printf("Gas limit=%v, max gas price=%v", tx.Gas(), tx.GasFeeCap())
+
+	if call, err := feeproxy.UnpackCall(tx.Data()); err == nil {
+		in.logFeeProxyCall(ctx, call, "")
+	} else {
+		in.logInput(tx.Data(), "")
+	}
+
+	receipt, err := feeproxy.NewWaiter(evmClient).Check(ctx, hash)
+	if err != nil {
+		return err
+	}
+	if receipt == nil {
+		log.Printf("Status: pending")
+		return nil
+	}
+
+	status := "succeeded"
+	if !receipt.Succeeded() {
+		status = "failed"
+	}
+	gasPrice := receipt.GasPrice(tx)
+	fee := new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), gasPrice)
+	log.Printf("Status: %v in block %v with %v confirmations", status, receipt.BlockNumber, receipt.Confirmations)
+	log.Printf("Gas used=%v, gas price=%v, fee=%v", receipt.GasUsed, gasPrice, feeproxy.NativeXRP.FormatAmount(fee))
+	if !receipt.Succeeded() && tx.To() != nil {
+		// replaying the transaction recovers its revert reason
+		client, err := feeproxy.NewClient(*tx.To(), evmClient, &bind.TransactOpts{From: sender})
+		if err != nil {
+			return err
+		}
+		if err := client.CheckReceipt(ctx, tx, receipt); err != nil {
+			log.Printf("Failure: %v", err)
+		}
+	}
+
+	events, err := feeproxy.TokenEvents(receipt.Receipt)
+	if err != nil {
+		return err
+	}
+	log.Printf("Token events: %v", len(events))
+	for _, event := range events {
+		amount := formatAmount(ctx, tokens, event.Token, event.Value)
+		if event.Name == "Approval" {
+			log.Printf("  Approval of %v by %v for %v", amount, event.From.Hex(), event.To.Hex())
+		} else {
+			log.Printf("  Transfer of %v from %v to %v", amount, event.From.Hex(), event.To.Hex())
+		}
+	}
+	return nil
+}
+
+// logFeeProxyCall logs the arguments of a fee proxy call and decodes its
+// inner call, following fee proxy calls nested in it.
+func (in *inspector) logFeeProxyCall(ctx context.Context, call *feeproxy.Call, indent string) {
+	log.Printf("%sFee proxy call paying at most %v (%v)", indent, formatAmount(ctx, in.tokens, call.Asset, call.MaxPayment), call.Asset.Hex())
+	log.Printf("%sTarget: %v", indent, call.Target.Hex())
+	if inner, err := feeproxy.UnpackCall(call.Input); err == nil {
+		in.logFeeProxyCall(ctx, inner, indent+"  ")
+		return
+	}
+	in.logInput(call.Input, indent)
+}
+
+// logInput logs input decoded with the known ABIs, or as hex if none of
+// them has its method.
+func (in *inspector) logInput(input []byte, indent string) {
+	if len(input) == 0 {
+		return
+	}
+	decoded, err := feeproxy.DecodeCall(in.abis, input)
+	if err != nil {
+		log.Printf("%sInput: %v", indent, hexutil.Encode(input))
+		return
+	}
+	log.Printf("%sCall: %v", indent, decoded)
+}
+
+// loadABIs returns the ERC-20 ABI followed by those in a comma separated
+// list of ABI files.
+func loadABIs(files string) ([]*abi.ABI, error) {
+	erc20, err := feeproxy.SyloTokenMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("could not get contract abi: %v", err)
+	}
+	abis := []*abi.ABI{erc20}
+	if files == "" {
+		return abis, nil
+	}
+	for _, path := range strings.Split(files, ",") {
+		contractABI, err := feeproxy.LoadABI(strings.TrimSpace(path))
+		if err != nil {
+			return nil, err
+		}
+		abis = append(abis, contractABI)
+	}
+	return abis, nil
+}
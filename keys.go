@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"go-fee-proxy-reference/feeproxy"
+)
+
+const keyFlagsHint = "use one of --signer, --keystore, --mnemonic-file, --key-file, --key-env or --key"
+
+// keyOptions are the flags choosing where the sender's key is loaded from.
+type keyOptions struct {
+	key            string
+	keyEnv         string
+	keyFile        string
+	mnemonicFile   string
+	passphraseFile string
+	hdPath         string
+	keystore       string
+	account        string
+	passwordFile   string
+	signer         string
+}
+
+func (k *keyOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&k.key, "key", "", "hex encoded private key of the sender (prefer --key-env or --key-file)")
+	fs.StringVar(&k.keyEnv, "key-env", defaultKeyEnv, "environment variable holding the hex encoded private key of the sender")
+	fs.StringVar(&k.keyFile, "key-file", "", "file holding the hex encoded private key of the sender")
+	fs.StringVar(&k.mnemonicFile, "mnemonic-file", "", "file holding a BIP-39 mnemonic of the sender")
+	fs.StringVar(&k.passphraseFile, "passphrase-file", "", "file holding the BIP-39 passphrase of the mnemonic, if it has one")
+	fs.StringVar(&k.hdPath, "hd-path", feeproxy.DefaultDerivationPath, "derivation path of the sender's key when using --mnemonic-file")
+	fs.StringVar(&k.keystore, "keystore", "", "keystore directory holding the sender's key")
+	fs.StringVar(&k.account, "account-address", "", "address of the sender's account in the keystore or external signer")
+	fs.StringVar(&k.passwordFile, "password-file", "", "file holding the password of the keystore account")
+	fs.StringVar(&k.signer, "signer", "", "URL or IPC path of an external signer, such as clef, holding the sender's key")
+}
+
+// source returns the key source picked by the flags. The key environment
+// variable is used when no other source is given.
+func (k *keyOptions) source() (feeproxy.KeySource, error) {
+	switch {
+	case k.signer != "":
+		return feeproxy.KeyFromRemoteSigner(k.signer, k.account)
+	case k.keystore != "":
+		return feeproxy.KeyFromKeystorePasswordFile(k.keystore, k.account, k.passwordFile)
+	case k.mnemonicFile != "":
+		var passphrase string
+		if k.passphraseFile != "" {
+			b, err := ioutil.ReadFile(k.passphraseFile)
+			if err != nil {
+				return nil, fmt.Errorf("could not read passphrase file: %v", err)
+			}
+			passphrase = strings.TrimRight(string(b), "\r\n")
+		}
+		return feeproxy.KeyFromMnemonicFile(k.mnemonicFile, passphrase, k.hdPath)
+	case k.keyFile != "":
+		return feeproxy.KeyFromFile(k.keyFile)
+	case k.key != "":
+		return feeproxy.KeyFromHex(k.key)
+	case k.keyEnv != "":
+		source, err := feeproxy.KeyFromEnv(k.keyEnv)
+		if err != nil {
+			return nil, fmt.Errorf("%v, %s", err, keyFlagsHint)
+		}
+		return source, nil
+	}
+	return nil, fmt.Errorf("no key given, %s", keyFlagsHint)
+}
@@ -1,165 +1,70 @@
 package main
 
 import (
-	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"math/big"
-	"time"
-
-	"github.com/ethereum/go-ethereum"
-	"github.com/ethereum/go-ethereum/accounts"
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
-	"github.com/ethereum/go-ethereum/accounts/keystore"
-	"github.com/ethereum/go-ethereum/common"
-	ethcrypto "github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"os"
+	"sort"
 )
 
-func run() error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
-	defer cancel()
-
-	// make temp data directory
-	tempStore, err := ioutil.TempDir("", "*-keystore")
-
-	if err != nil {
-		return fmt.Errorf("could not create test directory for keystore: %v", err)
-	}
-	ks := keystore.NewKeyStore(tempStore, keystore.LightScryptN, keystore.LightScryptP)
-
-	evmClient, err := ethclient.Dial("https://porcini.au.rootnet.app")
-	if err != nil {
-		return fmt.Errorf("could not create ethereum virtual client: %v", err)
-	}
-
-	// private key is just for testing :)
-	sk, err := ethcrypto.HexToECDSA("cb6df9de1efca7a3998a8ead4e02159d5fa99c3e0d4fd6432667390bb4726854")
-	if err != nil {
-		return fmt.Errorf("failed to derive private key: %v", err)
-	}
-	acct := accounts.Account{Address: ethcrypto.PubkeyToAddress(sk.PublicKey)}
-
-	log.Printf("Sending transaction from: %v", acct.Address.Hex())
-
-	_, err = ks.ImportECDSA(sk, "")
-	if err != nil {
-		return fmt.Errorf("failed to import private key: %v", err)
-	}
-	err = ks.Unlock(acct, "")
-	if err != nil {
-		return fmt.Errorf("failed to unlock account: %v", err)
-	}
-
-	chainID, err := evmClient.ChainID(ctx)
-	if err != nil {
-		return fmt.Errorf("could not get chain id: %v", err)
-	}
-
-	opts, err := bind.NewKeyStoreTransactorWithChainID(ks, acct, chainID)
-	if err != nil {
-		return fmt.Errorf("could not create keystore transactor: %v", err)
-	}
-
-	tokenAddress := common.HexToAddress("0xCCcCCcCC00000C64000000000000000000000000")
-	feeProxyAddress := common.HexToAddress("0x00000000000000000000000000000000000004bb")
-
-	token, err := NewSyloToken(tokenAddress, evmClient)
-	if err != nil {
-		return fmt.Errorf("failed to bind sylo token contract: %v", err)
-	}
-
-	syloBalance, err := token.BalanceOf(nil, acct.Address)
-	if err != nil {
-		return fmt.Errorf("failed to retrieve sylo balance: %v", err)
-	}
-
-	xrpBalance, err := evmClient.BalanceAt(ctx, acct.Address, nil)
-	if err != nil {
-		return fmt.Errorf("failed to retrieve xrp balance: %v", err)
-	}
-
-	log.Printf("Account XRP Balance: %v", xrpBalance.String())
-	log.Printf("Account Sylo Balance: %v", syloBalance.String())
-
-	feeProxy, err := NewFeeProxy(feeProxyAddress, evmClient)
-	if err != nil {
-		return fmt.Errorf("failed to bind fee proxy contract: %v", err)
-	}
-
-	// receiver of transfer
-	receiver := common.HexToAddress("0x25451A4de12dcCc2D166922fA938E900fCc4ED24")
-	// transfer amount
-	amount := big.NewInt(1)
-
-	transferData, err := packTxData(SyloTokenMetaData, "transfer", receiver, amount)
-	if err != nil {
-		return fmt.Errorf("could not derive input bytes: %w", err)
-	}
-
-	ETH := new(big.Int).SetInt64(int64(1e18))
-	maxFeePayment := new(big.Int).Mul(big.NewInt(100000), ETH) // 10000 SYLO, TODO: Use dex rpc
-
-	// Estimate Gas Limit for fee proxy transaction
-	feeProxyData, err := packTxData(FeeProxyMetaData, "callWithFeePreferences", tokenAddress, tokenAddress, transferData)
-	msg := ethereum.CallMsg{
-		From: opts.From,
-		To:   &feeProxyAddress,
-		Data: feeProxyData,
-	}
-	gasLimit, err := evmClient.EstimateGas(ctx, msg)
-	if err != nil {
-		return fmt.Errorf("could not estimate gas for fee proxy: %v", err)
-	}
-
-	log.Printf("Estimated gas limit for fee proxy transaction %v \n", gasLimit)
+// command is a subcommand of the fee proxy tool.
+type command struct {
+	usage string
+	run   func(args []string) error
+}
 
-	opts.GasLimit = gasLimit
-	opts.GasLimit = 250000 // The estimation is 21000, but for some reason needs to be a larger number else we get InvalidTransaction:Custom(3)
+var commands = map[string]command{
+	"balance":   {usage: "show XRP and fee asset balances of an account", run: cmdBalance},
+	"batch":     {usage: "send transfers listed in a CSV file and report the results", run: cmdBatch},
+	"broadcast": {usage: "send a transaction signed offline and wait for its receipt", run: cmdBroadcast},
+	"build":     {usage: "write a fee proxy transaction to a file for signing offline", run: cmdBuild},
+	"cancel":    {usage: "replace a pending transaction with a zero value transfer to the sender", run: cmdCancel},
+	"transfer":  {usage: "transfer tokens, paying the fee in the fee asset", run: cmdTransfer},
+	"call":      {usage: "call any contract with raw input or an ABI method, paying the fee in the fee asset", run: cmdCall},
+	"estimate":  {usage: "estimate gas for a fee proxy call", run: cmdEstimate},
+	"inspect":   {usage: "decode a fee proxy transaction, its inner call and its token events", run: cmdInspect},
+	"serve":     {usage: "serve an HTTP API relaying fee proxy calls", run: cmdServe},
+	"sign":      {usage: "sign a transaction written by build, without a node", run: cmdSign},
+	"speedup":   {usage: "resend a pending fee proxy transaction with higher fees", run: cmdSpeedup},
+	"token":     {usage: "show the name, symbol, decimals and supply of a token", run: cmdToken},
+}
 
-	feeProxySession := &FeeProxySession{
-		Contract:     feeProxy,
-		TransactOpts: *opts,
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
 	}
-
-	log.Printf("Sending Fee Proxy Transaction for token=%v, target=%v", tokenAddress.Hex(), tokenAddress.Hex())
-
-	tx, err := feeProxySession.CallWithFeePreferences(tokenAddress, maxFeePayment, tokenAddress, transferData)
-	if err != nil {
-		return fmt.Errorf("failed to send fee proxy transaction: %v", err)
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(flag.CommandLine.Output(), "  %-10s %s\n", name, commands[name].usage)
 	}
-
-	log.Printf("Sent Fee Proxy transaction: %v", tx.Hash())
-
-	log.Printf("Waiting for tx receipt...")
-
-	receipt, err := evmClient.TransactionReceipt(ctx, tx.Hash())
-	if err != nil {
-		return fmt.Errorf("failed to wait for tx receipt: %v", err)
-	}
-
-	log.Printf("Successfully received tx receipt. Gas used=%v", receipt.GasUsed)
-
-	return nil
+	fmt.Fprintf(flag.CommandLine.Output(), "\nRun '%s <command> -h' for the flags of a command.\n", os.Args[0])
 }
 
-func packTxData(contractMetadata *bind.MetaData, method string, params ...interface{}) ([]byte, error) {
-	abi, err := contractMetadata.GetAbi()
-	if err != nil {
-		return nil, fmt.Errorf("could not get contract abi: %v", err)
+func run(args []string) error {
+	if len(args) == 0 {
+		usage()
+		return fmt.Errorf("no command given")
 	}
-	input, err := abi.Pack(method, params...)
-	if err != nil {
-		return nil, fmt.Errorf("could not pack method (%s): %v", method, err)
+	cmd, ok := commands[args[0]]
+	if !ok {
+		usage()
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+	err := cmd.run(args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
 	}
-	return input, nil
+	return err
 }
 
 func main() {
-	err := run()
+	err := run(os.Args[1:])
 
 	if err != nil {
-		log.Panicf("%v", err)
+		log.Fatalf("%v", err)
 	}
 }
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"go-fee-proxy-reference/feeproxy"
+)
+
+// cmdBuild writes a fee proxy transaction to a file for signing offline. It
+// needs a node but not the sender's key.
+func cmdBuild(args []string) error {
+	var o options
+	var c callFlags
+	fs := newFlagSet("build", &o)
+	c.register(fs)
+	fs.StringVar(&o.from, "from", "", "address of the sender, so that no key is needed")
+	out := fs.String("out", "", "file to write the unsigned transaction JSON to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return fmt.Errorf("--out is required")
+	}
+
+	target, input, err := c.parse(&o, fs.Args())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+
+	s, err := o.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	maxPayment, err := s.prepare(ctx, target, input)
+	if err != nil {
+		return err
+	}
+	if s.preflight {
+		if err := s.client.Preflight(ctx, s.asset, maxPayment, target, input); err != nil {
+			return err
+		}
+	}
+
+	unsigned, err := s.client.Build(ctx, s.chainID, s.asset, maxPayment, target, input)
+	if err != nil {
+		return err
+	}
+	if err := feeproxy.WriteUnsignedTx(*out, unsigned); err != nil {
+		return err
+	}
+
+	log.Printf("Wrote unsigned fee proxy transaction from %v with nonce=%v to %v", unsigned.From.Hex(), uint64(unsigned.Nonce), *out)
+	return nil
+}
+
+// cmdSign signs a transaction written by build. It only needs the key, so it
+// can run on a machine without network access.
+func cmdSign(args []string) error {
+	var k keyOptions
+	fs := flag.NewFlagSet("sign", flag.ContinueOnError)
+	k.register(fs)
+	in := fs.String("in", "", "file holding the unsigned transaction JSON written by build")
+	out := fs.String("out", "", "file to write the signed raw transaction to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *in == "" || *out == "" {
+		return fmt.Errorf("--in and --out are required")
+	}
+
+	unsigned, err := feeproxy.LoadUnsignedTx(*in)
+	if err != nil {
+		return err
+	}
+	source, err := k.source()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Signing tx from %v to %v on chain %v, nonce=%v, gas limit=%v", unsigned.From.Hex(), unsigned.To.Hex(), unsigned.ChainID.ToInt(), uint64(unsigned.Nonce), uint64(unsigned.Gas))
+	if call, err := feeproxy.UnpackCall(unsigned.Data); err == nil {
+		log.Printf("Fee proxy call to %v with input %v, paying at most %v wei of %v", call.Target.Hex(), hexutil.Encode(call.Input), call.MaxPayment, call.Asset.Hex())
+	}
+
+	signed, err := unsigned.Sign(source)
+	if err != nil {
+		return err
+	}
+	if err := feeproxy.WriteSignedTx(*out, signed); err != nil {
+		return err
+	}
+
+	log.Printf("Wrote signed transaction %v to %v", signed.Hash(), *out)
+	return nil
+}
+
+// cmdBroadcast sends a transaction written by sign and waits for its receipt.
+func cmdBroadcast(args []string) error {
+	var o options
+	fs := newFlagSet("broadcast", &o)
+	in := fs.String("in", "", "file holding the signed raw transaction written by sign")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *in == "" {
+		return fmt.Errorf("--in is required")
+	}
+
+	tx, err := feeproxy.LoadSignedTx(*in)
+	if err != nil {
+		return err
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return fmt.Errorf("could not recover sender of tx %v: %v", tx.Hash().Hex(), err)
+	}
+	// the transaction is already signed, so no key is needed
+	o.from = sender.Hex()
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+
+	s, err := o.connect(ctx)
+	if err != nil {
+		return err
+	}
+	if tx.ChainId().Cmp(s.chainID) != 0 {
+		return fmt.Errorf("tx is signed for chain %v but the node is on chain %v", tx.ChainId(), s.chainID)
+	}
+
+	if err := s.client.Broadcast(ctx, tx); err != nil {
+		return err
+	}
+	log.Printf("Broadcast transaction: %v, nonce=%v", tx.Hash(), tx.Nonce())
+
+	_, err = s.confirm(ctx, tx)
+	return err
+}
@@ -0,0 +1,372 @@
+package relay
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+const (
+	// MetaCallDomainName and MetaCallDomainVersion name the EIP-712 domain
+	// meta calls are signed in.
+	MetaCallDomainName    = "FeeProxyRelay"
+	MetaCallDomainVersion = "2"
+
+	// DefaultMaxMetaDeadline is how far in the future a meta call's deadline
+	// may be.
+	DefaultMaxMetaDeadline = time.Hour
+
+	// DefaultMetaQuotaWindow and DefaultMetaQuotaPerSender limit each sender
+	// to 10 meta calls an hour by default.
+	DefaultMetaQuotaWindow    = time.Hour
+	DefaultMetaQuotaPerSender = 10
+)
+
+var (
+	// ErrInvalidSignature is returned when a meta call is not signed by its
+	// sender.
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrInvalidNonce is returned when a meta call's nonce is not the
+	// sender's next nonce.
+	ErrInvalidNonce = errors.New("invalid nonce")
+	// ErrQuotaExceeded is returned when a sender, or all senders together,
+	// have sent as many meta calls as the relay allows for now.
+	ErrQuotaExceeded = errors.New("meta call quota exceeded")
+)
+
+// metaCallTypes are the EIP-712 types of a meta call.
+var metaCallTypes = apitypes.Types{
+	"EIP712Domain": {
+		{Name: "name", Type: "string"},
+		{Name: "version", Type: "string"},
+		{Name: "chainId", Type: "uint256"},
+		{Name: "verifyingContract", Type: "address"},
+		{Name: "salt", Type: "bytes32"},
+	},
+	"FeeProxyCall": {
+		{Name: "from", Type: "address"},
+		{Name: "asset", Type: "address"},
+		{Name: "maxPayment", Type: "uint256"},
+		{Name: "target", Type: "address"},
+		{Name: "input", Type: "bytes"},
+		{Name: "nonce", Type: "uint256"},
+		{Name: "deadline", Type: "uint256"},
+	},
+}
+
+// MetaCallDomain is the EIP-712 domain of the meta calls a relay accepts.
+// Its verifying contract is the relayer, so a call signed for one relay
+// cannot be replayed on another. The salt is picked when the relay starts,
+// so calls signed before a restart cannot be replayed after it.
+type MetaCallDomain struct {
+	ChainID *big.Int
+	Relayer common.Address
+	Salt    common.Hash
+}
+
+// TypedDataDomain returns the domain as passed to eth_signTypedData_v4.
+func (d *MetaCallDomain) TypedDataDomain() apitypes.TypedDataDomain {
+	return apitypes.TypedDataDomain{
+		Name:              MetaCallDomainName,
+		Version:           MetaCallDomainVersion,
+		ChainId:           (*math.HexOrDecimal256)(d.ChainID),
+		VerifyingContract: d.Relayer.Hex(),
+		Salt:              d.Salt.Hex(),
+	}
+}
+
+// MetaCall is a fee proxy call signed by a user and sent by the relayer,
+// which pays the fee from its own account. The user's address is appended to
+// input, as in ERC-2771, so a target that trusts the relayer as a forwarder
+// can act for the user.
+type MetaCall struct {
+	From       common.Address        `json:"from"`
+	Asset      common.Address        `json:"asset"`
+	MaxPayment *math.HexOrDecimal256 `json:"maxPayment"`
+	Target     common.Address        `json:"target"`
+	Input      hexutil.Bytes         `json:"input"`
+	// Nonce is the sender's next meta call nonce, see GET /v1/meta/nonce.
+	Nonce math.HexOrDecimal64 `json:"nonce"`
+	// Deadline is the unix time after which the call may not be sent.
+	Deadline math.HexOrDecimal64 `json:"deadline"`
+}
+
+// MetaCallRequest is the body of POST /v1/meta/call.
+type MetaCallRequest struct {
+	Call      MetaCall      `json:"call"`
+	Signature hexutil.Bytes `json:"signature"`
+}
+
+// TypedData returns the EIP-712 typed data of the call in domain, as passed
+// to eth_signTypedData_v4.
+func (c *MetaCall) TypedData(domain *MetaCallDomain) apitypes.TypedData {
+	maxPayment := new(big.Int)
+	if c.MaxPayment != nil {
+		maxPayment = (*big.Int)(c.MaxPayment)
+	}
+	return apitypes.TypedData{
+		Types:       metaCallTypes,
+		PrimaryType: "FeeProxyCall",
+		Domain:      domain.TypedDataDomain(),
+		Message: apitypes.TypedDataMessage{
+			"from":       c.From.Hex(),
+			"asset":      c.Asset.Hex(),
+			"maxPayment": (*math.HexOrDecimal256)(maxPayment),
+			"target":     c.Target.Hex(),
+			"input":      []byte(c.Input),
+			"nonce":      (*math.HexOrDecimal256)(new(big.Int).SetUint64(uint64(c.Nonce))),
+			"deadline":   (*math.HexOrDecimal256)(new(big.Int).SetUint64(uint64(c.Deadline))),
+		},
+	}
+}
+
+// Hash returns the EIP-712 hash of the call that the sender signs.
+func (c *MetaCall) Hash(domain *MetaCallDomain) (common.Hash, error) {
+	hash, _, err := apitypes.TypedDataAndHash(c.TypedData(domain))
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("could not hash meta call: %v", err)
+	}
+	return common.BytesToHash(hash), nil
+}
+
+// Signer returns the address that produced signature over the call.
+func (c *MetaCall) Signer(domain *MetaCallDomain, signature []byte) (common.Address, error) {
+	if len(signature) != ethcrypto.SignatureLength {
+		return common.Address{}, fmt.Errorf("%w: expected %v bytes, got %v", ErrInvalidSignature, ethcrypto.SignatureLength, len(signature))
+	}
+	hash, err := c.Hash(domain)
+	if err != nil {
+		return common.Address{}, err
+	}
+	sig := make([]byte, len(signature))
+	copy(sig, signature)
+	// wallets return v as 27 or 28
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	pub, err := ethcrypto.SigToPub(hash.Bytes(), sig)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	return ethcrypto.PubkeyToAddress(*pub), nil
+}
+
+// SignMetaCall signs call in domain with key, as a wallet would with
+// eth_signTypedData_v4.
+func SignMetaCall(call *MetaCall, key *ecdsa.PrivateKey, domain *MetaCallDomain) ([]byte, error) {
+	hash, err := call.Hash(domain)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := ethcrypto.Sign(hash.Bytes(), key)
+	if err != nil {
+		return nil, fmt.Errorf("could not sign meta call: %v", err)
+	}
+	sig[64] += 27
+	return sig, nil
+}
+
+// metaNonces tracks the next meta call nonce of each sender. Nonces are only
+// kept in memory, which is safe as the domain salt changes on restart.
+type metaNonces struct {
+	mu   sync.Mutex
+	next map[common.Address]uint64
+}
+
+// get returns the next nonce of from.
+func (n *metaNonces) get(from common.Address) uint64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.next[from]
+}
+
+// use marks nonce of from as used if it is the next one.
+func (n *metaNonces) use(from common.Address, nonce uint64) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.next == nil {
+		n.next = make(map[common.Address]uint64)
+	}
+	if next := n.next[from]; nonce != next {
+		return fmt.Errorf("%w: expected %v, got %v", ErrInvalidNonce, next, nonce)
+	}
+	n.next[from] = nonce + 1
+	return nil
+}
+
+// release makes nonce of from usable again after the call failed to send,
+// unless a later nonce has been used since.
+func (n *metaNonces) release(from common.Address, nonce uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.next[from] == nonce+1 {
+		n.next[from] = nonce
+	}
+}
+
+// metaQuota counts the meta calls sent in the current window, in total and
+// by sender, as the relayer pays for all of them.
+type metaQuota struct {
+	mu      sync.Mutex
+	start   time.Time
+	total   int
+	senders map[common.Address]int
+}
+
+// take counts a call from from, unless it would exceed perSender calls from
+// from or total calls in the window. Zero limits are not enforced.
+func (q *metaQuota) take(from common.Address, window time.Duration, perSender, total int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if now := time.Now(); q.senders == nil || now.Sub(q.start) >= window {
+		q.start, q.total, q.senders = now, 0, make(map[common.Address]int)
+	}
+	if perSender > 0 && q.senders[from] >= perSender {
+		return fmt.Errorf("%w: %v has sent %v meta calls in the last %v", ErrQuotaExceeded, from.Hex(), perSender, window)
+	}
+	if total > 0 && q.total >= total {
+		return fmt.Errorf("%w: the relay has sent %v meta calls in the last %v", ErrQuotaExceeded, total, window)
+	}
+	q.senders[from]++
+	q.total++
+	return nil
+}
+
+// MetaDomain returns the EIP-712 domain meta calls to the relay are signed
+// in.
+func (s *Server) MetaDomain(ctx context.Context) (*MetaCallDomain, error) {
+	chainID, err := s.backend.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get chain id: %v", err)
+	}
+	return &MetaCallDomain{ChainID: chainID, Relayer: s.opts.From, Salt: s.metaSalt}, nil
+}
+
+// MetaNonce returns the nonce the next meta call from from must use.
+func (s *Server) MetaNonce(from common.Address) uint64 {
+	return s.metaNonces.get(from)
+}
+
+// SubmitMeta checks that req calls a forwarded target, is signed by an
+// allowed sender within its quota, has the sender's next nonce and has not
+// expired, then sends it through the fee proxy from the relayer.
+func (s *Server) SubmitMeta(ctx context.Context, req *MetaCallRequest) (*CallResponse, error) {
+	call := &req.Call
+	// a target that does not read the appended sender would act for the
+	// relayer, so a transfer would spend the relayer's tokens
+	if !s.forwarded(call.Target) {
+		return nil, fmt.Errorf("%w: target %v does not accept meta calls from the relayer", ErrInvalidRequest, call.Target.Hex())
+	}
+	if len(s.MetaSenders) != 0 && !contains(s.MetaSenders, call.From) {
+		return nil, fmt.Errorf("%w: %v may not send meta calls", ErrForbidden, call.From.Hex())
+	}
+	if call.MaxPayment == nil || (*big.Int)(call.MaxPayment).Sign() <= 0 {
+		return nil, fmt.Errorf("%w: max payment must be positive", ErrInvalidRequest)
+	}
+	if call.Asset == (common.Address{}) {
+		return nil, fmt.Errorf("%w: no asset given", ErrInvalidRequest)
+	}
+	now := time.Now()
+	deadline := time.Unix(int64(call.Deadline), 0)
+	if now.After(deadline) {
+		return nil, fmt.Errorf("%w: deadline has passed", ErrInvalidRequest)
+	}
+	if s.MaxMetaDeadline > 0 && deadline.Sub(now) > s.MaxMetaDeadline {
+		return nil, fmt.Errorf("%w: deadline is more than %v away", ErrInvalidRequest, s.MaxMetaDeadline)
+	}
+
+	domain, err := s.MetaDomain(ctx)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := call.Signer(domain, req.Signature)
+	if err != nil {
+		return nil, err
+	}
+	if signer != call.From {
+		return nil, fmt.Errorf("%w: signed by %v, not %v", ErrInvalidSignature, signer.Hex(), call.From.Hex())
+	}
+
+	nonce := uint64(call.Nonce)
+	if err := s.metaNonces.use(call.From, nonce); err != nil {
+		return nil, err
+	}
+	if err := s.metaQuota.take(call.From, s.MetaQuotaWindow, s.MetaQuotaPerSender, s.MetaQuotaTotal); err != nil {
+		s.metaNonces.release(call.From, nonce)
+		return nil, err
+	}
+
+	input := make([]byte, 0, len(call.Input)+common.AddressLength)
+	input = append(input, call.Input...)
+	input = append(input, call.From.Bytes()...)
+
+	resp, err := s.send(ctx, call.From, call.Asset, (*big.Int)(call.MaxPayment), call.Target, input, 0)
+	if err != nil {
+		s.metaNonces.release(call.From, nonce)
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s *Server) handleMetaCall(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %v not allowed", r.Method))
+		return
+	}
+	var req MetaCallRequest
+	if err := decodeBody(w, r, &req); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	resp, err := s.SubmitMeta(r.Context(), &req)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleMetaDomain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %v not allowed", r.Method))
+		return
+	}
+	domain, err := s.MetaDomain(r.Context())
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, domain.TypedDataDomain())
+}
+
+func (s *Server) handleMetaNonce(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %v not allowed", r.Method))
+		return
+	}
+	address := strings.TrimPrefix(r.URL.Path, "/v1/meta/nonce/")
+	if !common.IsHexAddress(address) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("%w: invalid address", ErrInvalidRequest))
+		return
+	}
+	from := common.HexToAddress(address)
+	writeJSON(w, http.StatusOK, struct {
+		Address common.Address `json:"address"`
+		Nonce   uint64         `json:"nonce"`
+	}{from, s.MetaNonce(from)})
+}
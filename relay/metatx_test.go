@@ -0,0 +1,141 @@
+package relay
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+func TestMetaCall(t *testing.T) {
+	b := newSimulated(t)
+	server, httpServer := newTestServer(t, b, b)
+	user := b.Accounts[1]
+	domain, err := server.MetaDomain(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transfer := transferRequest(t, b.Token, b.Accounts[0].Address, big.NewInt(1e18))
+	call := MetaCall{
+		From:       user.Address,
+		Asset:      b.Token,
+		MaxPayment: (*math.HexOrDecimal256)(big.NewInt(1e18)),
+		Target:     b.Token,
+		Input:      transfer.Data,
+		Deadline:   math.HexOrDecimal64(time.Now().Add(time.Minute).Unix()),
+	}
+	sig, err := SignMetaCall(&call, user.Key, domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &MetaCallRequest{Call: call, Signature: sig}
+
+	// the token ignores the appended sender, so relaying to it would
+	// transfer the relayer's tokens
+	var resp struct{ Error string }
+	if status := do(t, http.MethodPost, httpServer.URL+"/v1/meta/call", "", req, &resp); status != http.StatusBadRequest {
+		t.Errorf("POST /v1/meta/call to a target that is not forwarded = %v (%v), want %v", status, resp.Error, http.StatusBadRequest)
+	}
+
+	server.Forwarded = []common.Address{b.Token}
+	var callResp CallResponse
+	if status := do(t, http.MethodPost, httpServer.URL+"/v1/meta/call", "", req, &callResp); status != http.StatusOK {
+		t.Fatalf("POST /v1/meta/call = %v", status)
+	}
+	if nonce := server.MetaNonce(user.Address); nonce != 1 {
+		t.Errorf("MetaNonce = %v after a call, want 1", nonce)
+	}
+	if status := do(t, http.MethodPost, httpServer.URL+"/v1/meta/call", "", req, nil); status != http.StatusConflict {
+		t.Errorf("POST /v1/meta/call replayed = %v, want %v", status, http.StatusConflict)
+	}
+
+	// another relay, or this one after a restart, has its own domain
+	other, otherServer := newTestServer(t, b, b)
+	other.Forwarded = server.Forwarded
+	if status := do(t, http.MethodPost, otherServer.URL+"/v1/meta/call", "", req, nil); status != http.StatusUnauthorized {
+		t.Errorf("POST /v1/meta/call replayed on another relay = %v, want %v", status, http.StatusUnauthorized)
+	}
+
+	var served apitypes.TypedDataDomain
+	if status := do(t, http.MethodGet, httpServer.URL+"/v1/meta/domain", "", nil, &served); status != http.StatusOK {
+		t.Fatalf("GET /v1/meta/domain = %v", status)
+	}
+	if served.VerifyingContract != server.Relayer().Hex() || served.Salt != domain.Salt.Hex() || (*big.Int)(served.ChainId).Cmp(domain.ChainID) != 0 {
+		t.Errorf("GET /v1/meta/domain = %+v, want %+v", served, domain.TypedDataDomain())
+	}
+}
+
+func TestMetaCallLimits(t *testing.T) {
+	b := newSimulated(t)
+	server, httpServer := newTestServer(t, b, b)
+	server.Forwarded = []common.Address{b.Token}
+	server.MetaQuotaPerSender = 1
+	user := b.Accounts[1]
+	domain, err := server.MetaDomain(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	transfer := transferRequest(t, b.Token, b.Accounts[0].Address, big.NewInt(1e18))
+	signed := func(nonce uint64) *MetaCallRequest {
+		call := MetaCall{
+			From:       user.Address,
+			Asset:      b.Token,
+			MaxPayment: (*math.HexOrDecimal256)(big.NewInt(1e18)),
+			Target:     b.Token,
+			Input:      transfer.Data,
+			Nonce:      math.HexOrDecimal64(nonce),
+			Deadline:   math.HexOrDecimal64(time.Now().Add(time.Minute).Unix()),
+		}
+		sig, err := SignMetaCall(&call, user.Key, domain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &MetaCallRequest{Call: call, Signature: sig}
+	}
+
+	server.MetaSenders = []common.Address{b.Accounts[0].Address}
+	if status := do(t, http.MethodPost, httpServer.URL+"/v1/meta/call", "", signed(0), nil); status != http.StatusForbidden {
+		t.Errorf("POST /v1/meta/call from a sender not allowed = %v, want %v", status, http.StatusForbidden)
+	}
+	server.MetaSenders = nil
+
+	if status := do(t, http.MethodPost, httpServer.URL+"/v1/meta/call", "", signed(0), nil); status != http.StatusOK {
+		t.Fatalf("POST /v1/meta/call = %v", status)
+	}
+	if status := do(t, http.MethodPost, httpServer.URL+"/v1/meta/call", "", signed(1), nil); status != http.StatusTooManyRequests {
+		t.Errorf("POST /v1/meta/call over the quota = %v, want %v", status, http.StatusTooManyRequests)
+	}
+	// the refused call does not use up the nonce
+	if nonce := server.MetaNonce(user.Address); nonce != 1 {
+		t.Errorf("MetaNonce = %v after a refused call, want 1", nonce)
+	}
+}
+
+func TestMetaQuota(t *testing.T) {
+	var q metaQuota
+	a, b := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	take := func(from common.Address, window time.Duration, perSender, total int, ok bool) {
+		t.Helper()
+		if err := q.take(from, window, perSender, total); (err == nil) != ok {
+			t.Errorf("take(%v) = %v, want ok=%v", from.Hex(), err, ok)
+		}
+	}
+	take(a, time.Hour, 2, 3, true)
+	take(a, time.Hour, 2, 3, true)
+	take(a, time.Hour, 2, 3, false)
+	take(b, time.Hour, 2, 3, true)
+	take(b, time.Hour, 2, 3, false)
+	// a new window starts afresh
+	q.start = q.start.Add(-time.Hour)
+	take(a, time.Hour, 2, 3, true)
+	// zero limits are not enforced
+	for i := 0; i < 5; i++ {
+		take(b, time.Hour, 0, 0, true)
+	}
+}
@@ -0,0 +1,475 @@
+// Package relay serves an HTTP API that submits fee proxy calls from a relayer
+// account and reports the status of the transactions it sent.
+package relay
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"go-fee-proxy-reference/feeproxy"
+)
+
+// maxBodySize limits the size of request bodies.
+const maxBodySize = 1 << 20
+
+var (
+	// ErrInvalidRequest is returned for requests that are malformed or
+	// missing fields.
+	ErrInvalidRequest = errors.New("invalid request")
+	// ErrNotFound is returned when a transaction is not known to the node.
+	ErrNotFound = errors.New("transaction not found")
+	// ErrUnauthorized is returned when a request lacks the server's auth
+	// token.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden is returned for calls the server does not relay.
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Backend is the node client the relay sends transactions through.
+// ethclient.Client and the simulated backend both implement it.
+type Backend interface {
+	bind.ContractBackend
+	feeproxy.ReceiptBackend
+	TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error)
+	ChainID(ctx context.Context) (*big.Int, error)
+}
+
+// CallRequest is the body of POST /v1/call.
+type CallRequest struct {
+	// Asset is the asset the fee is paid in. The server's asset is used if
+	// it is not given.
+	Asset *common.Address `json:"asset,omitempty"`
+	// MaxPayment is the most of the asset to pay, as a decimal or 0x hex
+	// string. It is quoted from the dex if not given.
+	MaxPayment *math.HexOrDecimal256 `json:"maxPayment,omitempty"`
+	Target     *common.Address       `json:"target"`
+	Data       hexutil.Bytes         `json:"data"`
+	// GasLimit is estimated if zero.
+	GasLimit uint64 `json:"gasLimit,omitempty"`
+}
+
+// CallResponse is returned once a call has been submitted.
+type CallResponse struct {
+	TxHash     common.Hash    `json:"txHash"`
+	Nonce      uint64         `json:"nonce"`
+	Asset      common.Address `json:"asset"`
+	MaxPayment string         `json:"maxPayment"`
+	GasLimit   uint64         `json:"gasLimit"`
+	GasPrice   string         `json:"gasPrice"`
+}
+
+// TxStatus is the body of GET /v1/tx/{hash}. Status is pending, success or
+// failed. Confirmed is set once the transaction has the server's number of
+// confirmations.
+type TxStatus struct {
+	TxHash        common.Hash  `json:"txHash"`
+	Status        string       `json:"status"`
+	BlockNumber   uint64       `json:"blockNumber,omitempty"`
+	BlockHash     *common.Hash `json:"blockHash,omitempty"`
+	GasUsed       uint64       `json:"gasUsed,omitempty"`
+	Confirmations uint64       `json:"confirmations"`
+	Confirmed     bool         `json:"confirmed"`
+	Reason        string       `json:"reason,omitempty"`
+}
+
+// Server relays fee proxy calls over HTTP, signing them with the relayer's
+// transact opts. It is safe to serve requests concurrently.
+type Server struct {
+	backend  Backend
+	feeProxy common.Address
+	opts     *bind.TransactOpts
+	quoter   *feeproxy.Quoter
+	nonces   *feeproxy.NonceManager
+	waiter   *feeproxy.Waiter
+	mux      *http.ServeMux
+
+	metaNonces metaNonces
+	metaQuota  metaQuota
+	metaSalt   common.Hash
+
+	// Asset is the fee asset used when a request does not give one.
+	Asset common.Address
+	// GasOverhead and GasMultiplier tune gas estimates, as on
+	// feeproxy.GasEstimator.
+	GasOverhead   uint64
+	GasMultiplier uint64
+	// Confirmations is the number of blocks after which a transaction is
+	// reported as confirmed.
+	Confirmations uint64
+	// Fees prices the relayed transactions.
+	Fees *feeproxy.FeeStrategy
+	// Timeout limits how long a request may take. Zero means no limit.
+	Timeout time.Duration
+	// MaxMetaDeadline is how far in the future a meta call's deadline may
+	// be. Zero means no limit.
+	MaxMetaDeadline time.Duration
+	// AuthToken is the bearer token POST /v1/call requires, since the
+	// relayer signs and pays for those calls. The endpoint is disabled if it
+	// is empty.
+	AuthToken string
+	// Targets limits the contracts POST /v1/call may call. Any target is
+	// allowed if it is empty.
+	Targets []common.Address
+	// Forwarded are the ERC-2771 targets that trust the relayer as a
+	// forwarder and read the sender appended to meta calls. Meta calls to
+	// any other target are rejected, since they would act for the relayer.
+	Forwarded []common.Address
+	// MetaSenders limits the accounts that may send meta calls. Any account
+	// may if it is empty.
+	MetaSenders []common.Address
+	// MetaQuotaPerSender and MetaQuotaTotal limit the meta calls sent in
+	// each MetaQuotaWindow from one sender and from all of them, since the
+	// relayer pays their fees. Zero means no limit.
+	MetaQuotaWindow    time.Duration
+	MetaQuotaPerSender int
+	MetaQuotaTotal     int
+}
+
+// NewServer creates a relay sending through the fee proxy at feeProxy,
+// signing with opts and quoting max payments with quoter.
+func NewServer(backend Backend, feeProxy common.Address, opts *bind.TransactOpts, quoter *feeproxy.Quoter) (*Server, error) {
+	if opts == nil {
+		return nil, fmt.Errorf("transact opts must be provided")
+	}
+	if quoter == nil {
+		return nil, fmt.Errorf("quoter must be provided")
+	}
+	s := &Server{
+		backend:            backend,
+		feeProxy:           feeProxy,
+		opts:               opts,
+		quoter:             quoter,
+		nonces:             feeproxy.NewNonceManager(backend, opts.From),
+		waiter:             feeproxy.NewWaiter(backend),
+		mux:                http.NewServeMux(),
+		GasOverhead:        feeproxy.DefaultGasOverhead,
+		GasMultiplier:      feeproxy.DefaultGasMultiplier,
+		Confirmations:      1,
+		Fees:               feeproxy.NewFeeStrategy(backend, feeproxy.FeeNormal),
+		Timeout:            time.Minute,
+		MaxMetaDeadline:    DefaultMaxMetaDeadline,
+		MetaQuotaWindow:    DefaultMetaQuotaWindow,
+		MetaQuotaPerSender: DefaultMetaQuotaPerSender,
+	}
+	s.mux.HandleFunc("/v1/call", s.handleCall)
+	s.mux.HandleFunc("/v1/tx/", s.handleTx)
+	if _, err := rand.Read(s.metaSalt[:]); err != nil {
+		return nil, fmt.Errorf("could not generate meta call domain salt: %v", err)
+	}
+	s.mux.HandleFunc("/v1/meta/call", s.handleMetaCall)
+	s.mux.HandleFunc("/v1/meta/domain", s.handleMetaDomain)
+	s.mux.HandleFunc("/v1/meta/nonce/", s.handleMetaNonce)
+	return s, nil
+}
+
+// Relayer returns the address of the account that signs and pays for
+// relayed transactions.
+func (s *Server) Relayer() common.Address {
+	return s.opts.From
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Timeout > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// Submit sends a fee proxy call for req, estimating the gas limit and quoting
+// the max payment when they are not given.
+func (s *Server) Submit(ctx context.Context, req *CallRequest) (*CallResponse, error) {
+	asset := s.Asset
+	if req.Asset != nil {
+		asset = *req.Asset
+	}
+	if asset == (common.Address{}) {
+		return nil, fmt.Errorf("%w: no asset given", ErrInvalidRequest)
+	}
+	if req.Target == nil {
+		return nil, fmt.Errorf("%w: no target given", ErrInvalidRequest)
+	}
+	if !s.allowedTarget(*req.Target) {
+		return nil, fmt.Errorf("%w: target %v is not allowed", ErrForbidden, req.Target.Hex())
+	}
+	// a forwarded target reads the last 20 bytes of input from the relayer
+	// as the sender, so such input could act for any user
+	if s.forwarded(*req.Target) && len(req.Data) >= common.AddressLength {
+		return nil, fmt.Errorf("%w: target %v accepts meta calls, so direct calls to it could carry a sender", ErrForbidden, req.Target.Hex())
+	}
+	var maxPayment *big.Int
+	if req.MaxPayment != nil {
+		maxPayment = (*big.Int)(req.MaxPayment)
+		if maxPayment.Sign() <= 0 {
+			return nil, fmt.Errorf("%w: max payment must be positive", ErrInvalidRequest)
+		}
+	}
+	return s.send(ctx, s.opts.From, asset, maxPayment, *req.Target, req.Data, req.GasLimit)
+}
+
+// allowedTarget reports whether target is in Targets, or Targets is empty.
+func (s *Server) allowedTarget(target common.Address) bool {
+	return len(s.Targets) == 0 || contains(s.Targets, target)
+}
+
+// forwarded reports whether target is in Forwarded.
+func (s *Server) forwarded(target common.Address) bool {
+	return contains(s.Forwarded, target)
+}
+
+func contains(addresses []common.Address, address common.Address) bool {
+	for _, a := range addresses {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
+
+// send sends a fee proxy call from the relayer, whose inner call acts for
+// owner. Each call gets its own client so concurrent calls do not share gas
+// settings, while the nonce manager is shared between them.
+func (s *Server) send(ctx context.Context, owner, asset common.Address, maxPayment *big.Int, target common.Address, input []byte, gasLimit uint64) (*CallResponse, error) {
+	opts := *s.opts
+	fees, err := s.Fees.Fees(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fees.Apply(&opts)
+	// the max payment must cover the most the transaction can pay per gas
+	gasPrice := fees.MaxGasPrice()
+
+	client, err := feeproxy.NewClient(s.feeProxy, s.backend, &opts)
+	if err != nil {
+		return nil, err
+	}
+	client.SetNonceManager(s.nonces)
+
+	quote := func(ctx context.Context, gasLimit uint64) (*big.Int, error) {
+		if maxPayment != nil {
+			return maxPayment, nil
+		}
+		return s.quoter.MaxPayment(ctx, asset, gasLimit, gasPrice)
+	}
+	if gasLimit == 0 {
+		estimator := feeproxy.NewGasEstimator(client)
+		estimator.Overhead = s.GasOverhead
+		estimator.Multiplier = s.GasMultiplier
+		// a call that cannot be estimated would most likely revert, and the
+		// relayer would pay for it, so do not fall back
+		estimator.Fallback = 0
+		estimate, quoted, err := estimator.Prepare(ctx, asset, target, input, quote)
+		if err != nil {
+			return nil, err
+		}
+		gasLimit, maxPayment = estimate.GasLimit, quoted
+	} else if maxPayment, err = quote(ctx, gasLimit); err != nil {
+		return nil, err
+	}
+	opts.GasLimit = gasLimit
+
+	// the relayer pays for failed calls, so refuse any that would fail
+	if err := client.PreflightFor(ctx, owner, asset, maxPayment, target, input); err != nil {
+		return nil, err
+	}
+
+	tx, err := client.Send(ctx, asset, maxPayment, target, input)
+	if err != nil {
+		return nil, err
+	}
+	return &CallResponse{
+		TxHash:     tx.Hash(),
+		Nonce:      tx.Nonce(),
+		Asset:      asset,
+		MaxPayment: maxPayment.String(),
+		GasLimit:   tx.Gas(),
+		GasPrice:   tx.GasPrice().String(),
+	}, nil
+}
+
+// Status returns the status of the transaction with hash. Failed
+// transactions are replayed to find their revert reason.
+func (s *Server) Status(ctx context.Context, hash common.Hash) (*TxStatus, error) {
+	tx, pending, err := s.backend.TransactionByHash(ctx, hash)
+	if errors.Is(err, ethereum.NotFound) || (err == nil && tx == nil) {
+		return nil, fmt.Errorf("%w: %v", ErrNotFound, hash.Hex())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve tx %v: %w", hash.Hex(), err)
+	}
+	status := &TxStatus{TxHash: hash, Status: "pending"}
+	if pending {
+		return status, nil
+	}
+
+	receipt, err := s.waiter.Check(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if receipt == nil {
+		return status, nil
+	}
+
+	status.Status = "success"
+	status.BlockNumber = receipt.BlockNumber
+	status.BlockHash = &receipt.BlockHash
+	status.GasUsed = receipt.GasUsed
+	status.Confirmations = receipt.Confirmations
+	status.Confirmed = receipt.Confirmations >= s.Confirmations
+
+	if !receipt.Succeeded() {
+		status.Status = "failed"
+		// the call is replayed from the tx's sender, which is not the
+		// relayer for txs sent by anyone else through the fee proxy
+		sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+		if err != nil {
+			return nil, fmt.Errorf("could not recover sender of tx %v: %v", hash.Hex(), err)
+		}
+		client, err := feeproxy.NewClient(s.feeProxy, s.backend, &bind.TransactOpts{From: sender})
+		if err != nil {
+			return nil, err
+		}
+		var execErr *feeproxy.ExecutionError
+		if err := client.CheckReceipt(ctx, tx, receipt); errors.As(err, &execErr) {
+			status.Reason = execErr.Reason
+		}
+	}
+	return status, nil
+}
+
+func (s *Server) handleCall(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %v not allowed", r.Method))
+		return
+	}
+	if err := s.authorize(r); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	var req CallRequest
+	if err := decodeBody(w, r, &req); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	resp, err := s.Submit(r.Context(), &req)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleTx(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %v not allowed", r.Method))
+		return
+	}
+	hash, err := hexutil.Decode(strings.TrimPrefix(r.URL.Path, "/v1/tx/"))
+	if err != nil || len(hash) != common.HashLength {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("%w: invalid transaction hash", ErrInvalidRequest))
+		return
+	}
+	status, err := s.Status(r.Context(), common.BytesToHash(hash))
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// authorize checks that r carries the server's auth token as a bearer token.
+func (s *Server) authorize(r *http.Request) error {
+	if s.AuthToken == "" {
+		return fmt.Errorf("%w: no auth token is configured for direct calls", ErrForbidden)
+	}
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return fmt.Errorf("%w: missing or invalid bearer token", ErrUnauthorized)
+	}
+	token := strings.TrimPrefix(header, "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.AuthToken)) != 1 {
+		return fmt.Errorf("%w: missing or invalid bearer token", ErrUnauthorized)
+	}
+	return nil
+}
+
+// decodeBody decodes the JSON body of r into v, rejecting unknown fields.
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	return nil
+}
+
+// errorStatus returns the HTTP status for err. Calls the fee proxy or the
+// node rejects are the client's to fix, anything else is a server error.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidSignature), errors.Is(err, ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, ErrInvalidNonce):
+		return http.StatusConflict
+	case errors.Is(err, ErrQuotaExceeded):
+		return http.StatusTooManyRequests
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	}
+	var preflightErr *feeproxy.PreflightError
+	if errors.As(err, &preflightErr) {
+		return http.StatusUnprocessableEntity
+	}
+	for _, kind := range []error{
+		feeproxy.ErrInsufficientBalance,
+		feeproxy.ErrInsufficientAllowance,
+		feeproxy.ErrInnerInsufficientBalance,
+		feeproxy.ErrMaxPaymentTooLow,
+		feeproxy.ErrGasLimitTooLow,
+		feeproxy.ErrGasLimitTooHigh,
+		feeproxy.ErrGasPriceTooLow,
+		feeproxy.ErrUnknownAsset,
+	} {
+		if errors.Is(err, kind) {
+			return http.StatusUnprocessableEntity
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
@@ -0,0 +1,294 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"go-fee-proxy-reference/feeproxy"
+	"go-fee-proxy-reference/feeproxy/simulated"
+)
+
+const testToken = "secret"
+
+// newTestServer serves a relay sending from the first account of a
+// simulated backend, paying fees in its token.
+func newTestServer(t *testing.T, backend Backend, b *simulated.Backend) (*Server, *httptest.Server) {
+	t.Helper()
+	opts, err := b.Transactor(b.Accounts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	quoter, err := feeproxy.NewQuoter(feeproxy.DexAddress, b, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewServer(backend, feeproxy.DefaultAddress, opts, quoter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Asset = b.Token
+	server.AuthToken = testToken
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return server, httpServer
+}
+
+func newSimulated(t *testing.T) *simulated.Backend {
+	t.Helper()
+	b, err := simulated.NewBackend(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// do sends a request with an optional JSON body and decodes the response
+// into v, returning the status code.
+func do(t *testing.T, method, url, token string, body, v interface{}) int {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, url, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func transferRequest(t *testing.T, token, to common.Address, amount *big.Int) *CallRequest {
+	t.Helper()
+	input, err := feeproxy.PackTxData(feeproxy.SyloTokenMetaData, "transfer", to, amount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &CallRequest{Target: &token, Data: input}
+}
+
+func TestCall(t *testing.T) {
+	b := newSimulated(t)
+	_, httpServer := newTestServer(t, b, b)
+	req := transferRequest(t, b.Token, b.Accounts[1].Address, big.NewInt(1e18))
+
+	var resp CallResponse
+	if status := do(t, http.MethodPost, httpServer.URL+"/v1/call", testToken, req, &resp); status != http.StatusOK {
+		t.Fatalf("POST /v1/call = %v", status)
+	}
+	if resp.GasLimit == 0 || resp.MaxPayment == "" || resp.Asset != b.Token {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	var txStatus TxStatus
+	if status := do(t, http.MethodGet, httpServer.URL+"/v1/tx/"+resp.TxHash.Hex(), "", nil, &txStatus); status != http.StatusOK {
+		t.Fatalf("GET /v1/tx = %v", status)
+	}
+	if txStatus.Status != "success" || !txStatus.Confirmed || txStatus.BlockNumber == 0 {
+		t.Errorf("unexpected status %+v", txStatus)
+	}
+}
+
+func TestCallRejected(t *testing.T) {
+	b := newSimulated(t)
+	server, httpServer := newTestServer(t, b, b)
+	other := common.HexToAddress("0x25451A4de12dcCc2D166922fA938E900fCc4ED24")
+	valid := transferRequest(t, b.Token, b.Accounts[1].Address, big.NewInt(1e18))
+	tooMuch := transferRequest(t, b.Token, b.Accounts[1].Address, new(big.Int).Lsh(big.NewInt(1), 255))
+
+	tests := []struct {
+		name    string
+		token   string
+		targets []common.Address
+		req     interface{}
+		status  int
+	}{
+		{"no token", "", nil, valid, http.StatusUnauthorized},
+		{"wrong token", "wrong", nil, valid, http.StatusUnauthorized},
+		{"target not allowed", testToken, []common.Address{other}, valid, http.StatusForbidden},
+		{"no target", testToken, nil, &CallRequest{}, http.StatusBadRequest},
+		{"unknown field", testToken, nil, map[string]string{"to": other.Hex()}, http.StatusBadRequest},
+		{"insufficient balance", testToken, nil, tooMuch, http.StatusUnprocessableEntity},
+	}
+	for _, test := range tests {
+		server.Targets = test.targets
+		var resp struct{ Error string }
+		if status := do(t, http.MethodPost, httpServer.URL+"/v1/call", test.token, test.req, &resp); status != test.status {
+			t.Errorf("%v: POST /v1/call = %v (%v), want %v", test.name, status, resp.Error, test.status)
+		}
+	}
+
+	// direct calls could append a victim's address as the sender of a
+	// forwarded target
+	server.Targets = nil
+	server.Forwarded = []common.Address{b.Token}
+	if status := do(t, http.MethodPost, httpServer.URL+"/v1/call", testToken, valid, nil); status != http.StatusForbidden {
+		t.Errorf("POST /v1/call to a forwarded target = %v, want %v", status, http.StatusForbidden)
+	}
+	server.Forwarded = nil
+
+	// without an auth token direct calls are disabled
+	server.Targets = nil
+	server.AuthToken = ""
+	if status := do(t, http.MethodPost, httpServer.URL+"/v1/call", "", valid, nil); status != http.StatusForbidden {
+		t.Errorf("POST /v1/call without an auth token = %v, want %v", status, http.StatusForbidden)
+	}
+	if status := do(t, http.MethodGet, httpServer.URL+"/v1/call", testToken, nil, nil); status != http.StatusMethodNotAllowed {
+		t.Errorf("GET /v1/call = %v, want %v", status, http.StatusMethodNotAllowed)
+	}
+}
+
+// failingBackend fails to look up transactions with err.
+type failingBackend struct {
+	*simulated.Backend
+	err error
+}
+
+func (b *failingBackend) TransactionByHash(context.Context, common.Hash) (*types.Transaction, bool, error) {
+	return nil, false, b.err
+}
+
+func TestTxStatus(t *testing.T) {
+	b := newSimulated(t)
+	_, httpServer := newTestServer(t, b, b)
+	unknown := common.HexToHash("0x01")
+
+	if status := do(t, http.MethodGet, httpServer.URL+"/v1/tx/"+unknown.Hex(), "", nil, nil); status != http.StatusNotFound {
+		t.Errorf("GET /v1/tx of unknown tx = %v, want %v", status, http.StatusNotFound)
+	}
+	if status := do(t, http.MethodGet, httpServer.URL+"/v1/tx/0x1234", "", nil, nil); status != http.StatusBadRequest {
+		t.Errorf("GET /v1/tx of invalid hash = %v, want %v", status, http.StatusBadRequest)
+	}
+
+	// only a transaction the node does not know is not found
+	failing := &failingBackend{Backend: b, err: errors.New("connection refused")}
+	_, failingServer := newTestServer(t, failing, b)
+	if status := do(t, http.MethodGet, failingServer.URL+"/v1/tx/"+unknown.Hex(), "", nil, nil); status != http.StatusInternalServerError {
+		t.Errorf("GET /v1/tx with a failing node = %v, want %v", status, http.StatusInternalServerError)
+	}
+	failing.err = ethereum.NotFound
+	if status := do(t, http.MethodGet, failingServer.URL+"/v1/tx/"+unknown.Hex(), "", nil, nil); status != http.StatusNotFound {
+		t.Errorf("GET /v1/tx with a node not finding it = %v, want %v", status, http.StatusNotFound)
+	}
+}
+
+func TestTxStatusOfFailedTx(t *testing.T) {
+	b := newSimulated(t)
+	_, httpServer := newTestServer(t, b, b)
+	ctx := context.Background()
+
+	// a tx sent through the fee proxy by an account other than the relayer,
+	// failing for want of a balance the relayer does have
+	sender := b.Accounts[1]
+	opts, err := b.Transactor(sender)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.GasLimit = 300000
+	client, err := feeproxy.NewClient(feeproxy.DefaultAddress, b, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := feeproxy.NewSyloTokenCaller(b.Token, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	balance, err := token.BalanceOf(nil, sender.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := transferRequest(t, b.Token, b.Accounts[0].Address, new(big.Int).Add(balance, big.NewInt(1)))
+	tx, err := client.Send(ctx, b.Token, big.NewInt(1e18), b.Token, req.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var txStatus TxStatus
+	if status := do(t, http.MethodGet, httpServer.URL+"/v1/tx/"+tx.Hash().Hex(), "", nil, &txStatus); status != http.StatusOK {
+		t.Fatalf("GET /v1/tx = %v", status)
+	}
+	if txStatus.Status != "failed" || txStatus.Reason != "ERC20: transfer amount exceeds balance" {
+		t.Errorf("status of failed tx = %q with reason %q", txStatus.Status, txStatus.Reason)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	server := &Server{AuthToken: testToken}
+	tests := []struct {
+		header string
+		ok     bool
+	}{
+		{"Bearer " + testToken, true},
+		{testToken, false},
+		{"bearer " + testToken, false},
+		{"Basic " + testToken, false},
+		{"Bearer", false},
+		{"Bearer wrong", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/v1/call", nil)
+		if test.header != "" {
+			r.Header.Set("Authorization", test.header)
+		}
+		err := server.authorize(r)
+		if test.ok && err != nil {
+			t.Errorf("authorize(%q): %v", test.header, err)
+		}
+		if !test.ok && !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("authorize(%q) = %v, want %v", test.header, err, ErrUnauthorized)
+		}
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{fmt.Errorf("%w: no target", ErrInvalidRequest), http.StatusBadRequest},
+		{fmt.Errorf("%w: 0x01", ErrNotFound), http.StatusNotFound},
+		{ErrInvalidSignature, http.StatusUnauthorized},
+		{ErrUnauthorized, http.StatusUnauthorized},
+		{ErrForbidden, http.StatusForbidden},
+		{fmt.Errorf("%w: expected 1, got 0", ErrInvalidNonce), http.StatusConflict},
+		{fmt.Errorf("failed to send: %w", context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{&feeproxy.PreflightError{}, http.StatusUnprocessableEntity},
+		{fmt.Errorf("failed to send: %w", feeproxy.ErrInsufficientBalance), http.StatusUnprocessableEntity},
+		{feeproxy.ErrInnerInsufficientBalance, http.StatusUnprocessableEntity},
+		{feeproxy.ErrGasPriceTooLow, http.StatusUnprocessableEntity},
+		{feeproxy.ErrUnknownAsset, http.StatusUnprocessableEntity},
+		{feeproxy.ErrNonceTooLow, http.StatusInternalServerError},
+		{errors.New("connection refused"), http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		if status := errorStatus(test.err); status != test.status {
+			t.Errorf("errorStatus(%v) = %v, want %v", test.err, status, test.status)
+		}
+	}
+}
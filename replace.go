@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"go-fee-proxy-reference/feeproxy"
+)
+
+func cmdSpeedup(args []string) error {
+	return replace("speedup", args)
+}
+
+func cmdCancel(args []string) error {
+	return replace("cancel", args)
+}
+
+// replace replaces a pending transaction of the sender with one paying
+// higher fees, either resending its fee proxy call for speedup or calling the
+// sender with nothing for cancel, then waits for either to be mined.
+func replace(name string, args []string) error {
+	var o options
+	fs := newFlagSet(name, &o)
+	bump := fs.Uint64("fee-bump", feeproxy.MinFeeBumpPercent, "least percentage the fees of the pending transaction are raised by")
+	var withXRP *bool
+	if name == "cancel" {
+		withXRP = fs.Bool("xrp", false, "cancel with a plain XRP transfer instead of a fee proxy call paid in the fee asset")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected the hash of one pending transaction")
+	}
+	hash, err := parseHash("transaction", fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *bump < feeproxy.MinFeeBumpPercent {
+		return fmt.Errorf("fee bump must be at least %v%% for nodes to accept the replacement", feeproxy.MinFeeBumpPercent)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+
+	s, err := o.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.pendingTx(ctx, hash)
+	if err != nil {
+		return err
+	}
+	current, err := s.fees.Fees(ctx)
+	if err != nil {
+		return err
+	}
+	fees := feeproxy.BumpFees(tx, current, *bump)
+	log.Printf("Replacing tx %v with nonce=%v, fees: %v", hash, tx.Nonce(), fees)
+
+	var replacement *types.Transaction
+	switch {
+	case name == "cancel" && *withXRP:
+		if err = s.checkCancelXRP(ctx, fees); err != nil {
+			return err
+		}
+		replacement, err = s.client.CancelWithXRP(ctx, tx, fees)
+	case name == "cancel":
+		asset := s.asset
+		// the sender holds the asset the pending call pays in
+		if call, err := feeproxy.UnpackCall(tx.Data()); err == nil {
+			asset = call.Asset
+		}
+		var estimate *feeproxy.GasEstimate
+		var maxPayment *big.Int
+		if estimate, maxPayment, err = s.prepareCancel(ctx, asset, fees); err != nil {
+			return err
+		}
+		replacement, err = s.client.Cancel(ctx, tx, fees, asset, maxPayment, estimate.GasLimit)
+	default:
+		var maxPayment *big.Int
+		if maxPayment, err = s.requoteMaxPayment(ctx, tx, fees); err != nil {
+			return err
+		}
+		replacement, err = s.client.Speedup(ctx, tx, fees, maxPayment)
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Sent replacement transaction: %v, nonce=%v", replacement.Hash(), replacement.Nonce())
+
+	_, err = s.confirm(ctx, tx, replacement)
+	return err
+}
+
+// pendingTx returns the pending transaction with hash, which must have been
+// sent by the session's account.
+func (s *session) pendingTx(ctx context.Context, hash common.Hash) (*types.Transaction, error) {
+	tx, pending, err := s.evmClient.TransactionByHash(ctx, hash)
+	if errors.Is(err, ethereum.NotFound) {
+		return nil, fmt.Errorf("tx %v not found", hash.Hex())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve tx %v: %v", hash.Hex(), err)
+	}
+	if !pending {
+		return nil, fmt.Errorf("tx %v is already mined", hash.Hex())
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return nil, fmt.Errorf("could not recover sender of tx %v: %v", hash.Hex(), err)
+	}
+	if sender != s.opts.From {
+		return nil, fmt.Errorf("tx %v was sent by %v, not %v", hash.Hex(), sender.Hex(), s.opts.From.Hex())
+	}
+	return tx, nil
+}
+
+// prepareCancel estimates the gas limit of a fee proxy call doing nothing,
+// paid in asset with fees, and quotes its max payment.
+func (s *session) prepareCancel(ctx context.Context, asset common.Address, fees *feeproxy.Fees) (*feeproxy.GasEstimate, *big.Int, error) {
+	quote := func(ctx context.Context, gasLimit uint64) (*big.Int, error) {
+		if s.maxPayment != nil {
+			return s.maxPayment, nil
+		}
+		return s.quoter.MaxPayment(ctx, asset, gasLimit, fees.MaxGasPrice())
+	}
+	estimate, maxPayment, err := s.estimator.Prepare(ctx, asset, s.opts.From, nil, quote)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("Cancelling with a fee proxy call paying at most %v, gas limit=%v", s.format(ctx, asset, maxPayment), estimate.GasLimit)
+	return estimate, maxPayment, nil
+}
+
+// checkCancelXRP checks that the sender holds the XRP a plain transfer with
+// fees may cost.
+func (s *session) checkCancelXRP(ctx context.Context, fees *feeproxy.Fees) error {
+	cost := new(big.Int).Mul(big.NewInt(feeproxy.CancelGasLimit), fees.MaxGasPrice())
+	balance, err := s.evmClient.BalanceAt(ctx, s.opts.From, nil)
+	if err != nil {
+		return fmt.Errorf("could not get XRP balance of %v: %v", s.opts.From.Hex(), err)
+	}
+	if balance.Cmp(cost) < 0 {
+		return fmt.Errorf("%w: cancelling with XRP may cost %v but %v holds %v, cancel without --xrp to pay in the fee asset",
+			feeproxy.ErrInsufficientBalance, feeproxy.NativeXRP.FormatAmount(cost), s.opts.From.Hex(), feeproxy.NativeXRP.FormatAmount(balance))
+	}
+	return nil
+}
+
+// requoteMaxPayment returns the max payment for resending the fee proxy call
+// of tx with fees. It is the one given on the command line, or else a fresh
+// quote for the higher fees but never less than tx allowed.
+func (s *session) requoteMaxPayment(ctx context.Context, tx *types.Transaction, fees *feeproxy.Fees) (*big.Int, error) {
+	if s.maxPayment != nil {
+		return s.maxPayment, nil
+	}
+	call, err := feeproxy.UnpackCall(tx.Data())
+	if err != nil {
+		return nil, err
+	}
+	maxPayment, err := s.quoter.MaxPayment(ctx, call.Asset, tx.Gas(), fees.MaxGasPrice())
+	if err != nil {
+		return nil, err
+	}
+	if maxPayment.Cmp(call.MaxPayment) < 0 {
+		maxPayment = call.MaxPayment
+	}
+	log.Printf("Quoted max payment of %v for gas limit=%v, max gas price=%v", s.format(ctx, call.Asset, maxPayment), tx.Gas(), fees.MaxGasPrice())
+	return maxPayment, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"go-fee-proxy-reference/relay"
+)
+
+func cmdServe(args []string) error {
+	var o options
+	fs := newFlagSet("serve", &o)
+	listen := fs.String("listen", "localhost:8080", "address to serve the relay API on")
+	authTokenFile := fs.String("auth-token-file", "", "file holding the bearer token POST /v1/call requires, which is disabled without one")
+	allowTargets := fs.String("allow-targets", "", "comma separated addresses or token symbols of the contracts POST /v1/call may call, any if empty")
+	metaSenders := fs.String("meta-senders", "", "comma separated addresses of the only accounts that may send meta calls, any if empty")
+	metaPerSender := fs.Int("meta-per-sender", relay.DefaultMetaQuotaPerSender, "meta calls each sender may send per hour, 0 for no limit")
+	metaTotal := fs.Int("meta-total", 0, "meta calls all senders together may send per hour, 0 for no limit")
+	forwardedTargets := fs.String("forwarded-targets", "", "comma separated addresses of the ERC-2771 contracts that trust the relayer as a forwarder, the only targets of meta calls")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var authToken string
+	if *authTokenFile != "" {
+		b, err := ioutil.ReadFile(*authTokenFile)
+		if err != nil {
+			return fmt.Errorf("could not read auth token file: %v", err)
+		}
+		if authToken = strings.TrimSpace(string(b)); authToken == "" {
+			return fmt.Errorf("auth token file %v is empty", *authTokenFile)
+		}
+	}
+	targets, err := parseAddressList(&o, "allowed target", *allowTargets)
+	if err != nil {
+		return err
+	}
+	forwarded, err := parseAddressList(&o, "forwarded target", *forwardedTargets)
+	if err != nil {
+		return err
+	}
+	senders, err := parseAddressList(&o, "meta sender", *metaSenders)
+	if err != nil {
+		return err
+	}
+
+	connectCtx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	s, err := o.connect(connectCtx)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	server, err := relay.NewServer(s.evmClient, s.client.Address(), s.opts, s.quoter)
+	if err != nil {
+		return err
+	}
+	server.Asset = s.asset
+	server.GasOverhead = o.overhead
+	server.GasMultiplier = o.multiplier
+	server.Confirmations = o.confirms
+	server.Fees = s.fees
+	server.Timeout = o.timeout
+	server.AuthToken = authToken
+	server.Targets = targets
+	server.Forwarded = forwarded
+	server.MetaSenders = senders
+	server.MetaQuotaWindow = time.Hour
+	server.MetaQuotaPerSender = *metaPerSender
+	server.MetaQuotaTotal = *metaTotal
+
+	httpServer := &http.Server{
+		Addr:              *listen,
+		Handler:           server,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- httpServer.ListenAndServe()
+	}()
+	log.Printf("Relaying fee proxy calls from %v on %v", server.Relayer().Hex(), *listen)
+	if authToken == "" {
+		log.Printf("POST /v1/call is disabled, give --auth-token-file to enable it")
+	}
+	if len(forwarded) == 0 {
+		log.Printf("POST /v1/meta/call is disabled, give --forwarded-targets to enable it")
+	}
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("relay server failed: %v", err)
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down relay server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("could not shut down relay server: %v", err)
+	}
+	return nil
+}
+
+// parseAddressList parses a comma separated list of addresses or token
+// symbols.
+func parseAddressList(o *options, name, list string) ([]common.Address, error) {
+	if list == "" {
+		return nil, nil
+	}
+	var targets []common.Address
+	for _, s := range strings.Split(list, ",") {
+		target, err := o.token(name, strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}